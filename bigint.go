@@ -0,0 +1,220 @@
+package muon
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// Arbitrary-precision numbers from math/big are written as follows:
+//
+//   - big.Int is an integer: SLEB128 (0xBB) with as many bytes as the value
+//     needs once it no longer fits in 64 bits.
+//   - big.Rat is a list of two integers, numerator and denominator:
+//     [1 3] for 1/3. The denominator is always positive.
+//   - big.Float is a string holding its mantissa exactly in hexadecimal, with
+//     a binary exponent ("0x.cp+2" for 3, "-Inf"), so it reads back to the
+//     same value whatever its precision. A target of precision 0 gets the
+//     precision the mantissa needs, at least 64 bits; decimal strings are
+//     read at 64 bits.
+//
+// [Reader] returns SLEB128 integers that do not fit in int64 or uint64 as
+// *big.Int, so they survive decoding into interface{} unchanged.
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
+// isBigType reports whether t is big.Int, big.Rat or big.Float.
+func isBigType(t reflect.Type) bool {
+	return t == bigIntType || t == bigRatType || t == bigFloatType
+}
+
+// bigAddr returns a pointer to the math/big value v, copying v when it is
+// not addressable.
+func bigAddr(v reflect.Value) interface{} {
+	if !v.CanAddr() {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+	return v.Addr().Interface()
+}
+
+func bigEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	switch x := bigAddr(v).(type) {
+	case *big.Int:
+		return e.writeBigInt(w, x)
+	case *big.Rat:
+		if err := e.writeByte(w, listStart); err != nil {
+			return err
+		}
+		if err := e.writeBigInt(w, x.Num()); err != nil {
+			return err
+		}
+		if err := e.writeBigInt(w, x.Denom()); err != nil {
+			return err
+		}
+		return e.writeByte(w, listEnd)
+	case *big.Float:
+		return e.writeString(w, x.Text('p', 0))
+	}
+	return fmt.Errorf("type %s not supportable", v.Type())
+}
+
+func (e *Encoder) writeBigInt(w io.Writer, x *big.Int) error {
+	if x.IsInt64() {
+		return e.writeInt64(w, x.Int64())
+	}
+	b := append(e.scratch[:0], 0xBB)
+	return e.flushScratch(w, appendBigSleb128(b, x))
+}
+
+// appendBigSleb128 appends the SLEB128 encoding of x to b.
+func appendBigSleb128(b []byte, x *big.Int) []byte {
+	v := new(big.Int).Set(x)
+	low := new(big.Int)
+	mask := big.NewInt(0x7f)
+	for {
+		c := byte(low.And(v, mask).Uint64())
+		v.Rsh(v, 7) // arithmetic shift, so negative values end at -1
+		if v.Sign() == 0 && c&0x40 == 0 || v.Sign() < 0 && v.IsInt64() && v.Int64() == -1 && c&0x40 != 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// decodeBigSleb128 decodes the SLEB128 integer at the start of b. It
+// returns n == 0 when b ends before the last byte of the integer.
+func decodeBigSleb128(b []byte) (x *big.Int, n int) {
+	x = new(big.Int)
+	group := new(big.Int)
+	for i, c := range b {
+		x.Or(x, group.Lsh(group.SetUint64(uint64(c&0x7f)), uint(7*i)))
+		if c&0x80 == 0 {
+			if c&0x40 != 0 {
+				x.Sub(x, group.Lsh(group.SetUint64(1), uint(7*(i+1))))
+			}
+			return x, i + 1
+		}
+	}
+	return nil, 0
+}
+
+// bigIntValue returns the value of an integer token: a small int, an
+// int64, a uint64 or a *big.Int.
+func bigIntValue(v interface{}) (*big.Int, bool) {
+	switch n := v.(type) {
+	case int:
+		return big.NewInt(int64(n)), true
+	case int64:
+		return big.NewInt(n), true
+	case uint64:
+		return new(big.Int).SetUint64(n), true
+	case *big.Int:
+		return n, true
+	}
+	return nil, false
+}
+
+func (d *Decoder) unmarshalBig(tok Token, v reflect.Value) error {
+	switch x := v.Addr().Interface().(type) {
+	case *big.Int:
+		switch tok.A {
+		case TokenInt:
+			n, _ := bigIntValue(tok.Data)
+			x.Set(n)
+			return nil
+		case TokenString:
+			if _, ok := x.SetString(tok.Data.(string), 10); !ok {
+				return fmt.Errorf("invalid big.Int %q", tok.Data)
+			}
+			return nil
+		}
+	case *big.Rat:
+		switch tok.A {
+		case TokenInt:
+			n, _ := bigIntValue(tok.Data)
+			x.SetInt(n)
+			return nil
+		case TokenFloat:
+			if x.SetFloat64(tok.Data.(float64)) == nil {
+				return fmt.Errorf("invalid big.Rat %v", tok.Data)
+			}
+			return nil
+		case TokenString:
+			if _, ok := x.SetString(tok.Data.(string)); !ok {
+				return fmt.Errorf("invalid big.Rat %q", tok.Data)
+			}
+			return nil
+		case TokenListStart:
+			return d.readRat(x)
+		}
+	case *big.Float:
+		switch tok.A {
+		case TokenInt:
+			n, _ := bigIntValue(tok.Data)
+			x.SetInt(n)
+			return nil
+		case TokenFloat:
+			x.SetFloat64(tok.Data.(float64))
+			return nil
+		case TokenString:
+			s := tok.Data.(string)
+			if p := hexMantissaPrec(s); x.Prec() == 0 && p > 64 {
+				x.SetPrec(p)
+			}
+			if _, _, err := x.Parse(s, 0); err != nil {
+				return fmt.Errorf("invalid big.Float %q: %w", tok.Data, err)
+			}
+			return nil
+		}
+	}
+	return errTypeMismatch(tok.A, v.Interface())
+}
+
+// readRat reads the rest of a [numerator denominator] list into x.
+func (d *Decoder) readRat(x *big.Rat) error {
+	var parts [2]*big.Int
+	for i := 0; ; i++ {
+		tok, err := d.r.Next()
+		if err != nil {
+			return err
+		}
+		if tok.A == TokenListEnd {
+			if i != 2 {
+				return fmt.Errorf("big.Rat needs 2 integers, got %d", i)
+			}
+			break
+		}
+		n, ok := bigIntValue(tok.Data)
+		if tok.A != TokenInt || !ok || i >= 2 {
+			return errTypeMismatch(tok.A, x)
+		}
+		parts[i] = n
+	}
+	if parts[1].Sign() == 0 {
+		return fmt.Errorf("big.Rat with zero denominator")
+	}
+	x.SetFrac(parts[0], parts[1])
+	return nil
+}
+
+// hexMantissaPrec returns the precision that holds the mantissa of s, a
+// big.Float in 'p' format, exactly, or 0 when s is in another format.
+func hexMantissaPrec(s string) uint {
+	s = strings.TrimLeft(s, "+-")
+	if !strings.HasPrefix(s, "0x") {
+		return 0
+	}
+	m := s[2:]
+	if i := strings.IndexByte(m, 'p'); i >= 0 {
+		m = m[:i]
+	}
+	return uint(4 * len(strings.Replace(m, ".", "", 1)))
+}
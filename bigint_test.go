@@ -0,0 +1,160 @@
+package muon
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"ekyu.moe/leb128"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bigInt(t *testing.T, s string) *big.Int {
+	x, ok := new(big.Int).SetString(s, 10)
+	require.True(t, ok, s)
+	return x
+}
+
+func TestBigSleb128(t *testing.T) {
+	for _, v := range []int64{0, 1, -1, 63, 64, -64, -65, 1 << 40, math.MaxInt64, math.MinInt64} {
+		b := appendBigSleb128(nil, big.NewInt(v))
+		assert.Equal(t, leb128.AppendSleb128(nil, v), b, v)
+		x, n := decodeBigSleb128(b)
+		assert.Equal(t, len(b), n)
+		assert.Equal(t, v, x.Int64())
+	}
+	for _, s := range []string{"18446744073709551615", "-9223372036854775809", "123456789012345678901234567890", "-170141183460469231731687303715884105728"} {
+		b := appendBigSleb128(nil, bigInt(t, s))
+		x, n := decodeBigSleb128(b)
+		require.Equal(t, len(b), n)
+		assert.Equal(t, s, x.String())
+	}
+	_, n := decodeBigSleb128([]byte{0x80, 0x80})
+	assert.Zero(t, n)
+}
+
+func TestBigInt(t *testing.T) {
+	huge := bigInt(t, "-123456789012345678901234567890")
+	tests := []struct {
+		name string
+		in   interface{}
+		data interface{} // Token.Data
+	}{
+		{"small", big.NewInt(12), 12},
+		{"int64", big.NewInt(math.MinInt64), math.MinInt64},
+		{"uint64", new(big.Int).SetUint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{"huge", huge, huge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encode(t, tt.in)
+			assert.Equal(t, []Token{{A: TokenInt, Data: tt.data}}, tokens(t, data))
+
+			var out big.Int
+			require.NoError(t, Unmarshal(data, &out))
+			assert.Equal(t, tt.in.(*big.Int).String(), out.String())
+
+			var v interface{}
+			require.NoError(t, Unmarshal(data, &v))
+			assert.Equal(t, tt.data, v)
+		})
+	}
+
+	t.Run("overflow", func(t *testing.T) {
+		data := encode(t, huge)
+		var i int64
+		require.Error(t, Unmarshal(data, &i))
+		var u uint64
+		require.Error(t, Unmarshal(data, &u))
+		require.NoError(t, Unmarshal(encode(t, uint64(math.MaxUint64)), &u))
+		assert.Equal(t, uint64(math.MaxUint64), u)
+	})
+
+	t.Run("field", func(t *testing.T) {
+		type S struct {
+			N *big.Int `muon:"n"`
+			M big.Int  `muon:"m"`
+		}
+		in := S{N: huge}
+		in.M.SetUint64(math.MaxUint64)
+		var out S
+		require.NoError(t, Unmarshal(encode(t, &in), &out))
+		assert.Equal(t, huge.String(), out.N.String())
+		assert.Equal(t, in.M.String(), out.M.String())
+	})
+
+	t.Run("int key", func(t *testing.T) {
+		// map order is random, so pin both key orders: the first key carries
+		// the type byte, the second is raw bytes
+		want := map[uint64]string{math.MaxUint64: "max", 1: "one"}
+		for _, in := range []Dict{
+			{{Key: uint64(1), Value: "one"}, {Key: uint64(math.MaxUint64), Value: "max"}},
+			{{Key: uint64(math.MaxUint64), Value: "max"}, {Key: uint64(1), Value: "one"}},
+		} {
+			var out map[uint64]string
+			require.NoError(t, Unmarshal(encode(t, in), &out))
+			assert.Equal(t, want, out)
+		}
+
+		var out map[uint64]string
+		require.NoError(t, Unmarshal(encodeWith(t, &Encoder{Deterministic: true}, want), &out))
+		assert.Equal(t, want, out)
+	})
+}
+
+func TestBigRat(t *testing.T) {
+	in := big.NewRat(-1, 3)
+	data := encode(t, in)
+	assert.Equal(t, []Token{
+		{A: TokenListStart}, {A: TokenInt, Data: -1}, {A: TokenInt, Data: 3}, {A: TokenListEnd},
+	}, tokens(t, data))
+
+	var out big.Rat
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, "-1/3", out.String())
+
+	for src, want := range map[interface{}]string{7: "7/1", 0.25: "1/4", "2/6": "1/3"} {
+		require.NoError(t, Unmarshal(encode(t, src), &out))
+		assert.Equal(t, want, out.String())
+	}
+	require.Error(t, Unmarshal(encode(t, []int{1, 0}), &out))
+	require.Error(t, Unmarshal(encode(t, []int{1}), &out))
+}
+
+func TestBigFloat(t *testing.T) {
+	in, _, err := big.ParseFloat("3.14159265358979323846264338327950288", 10, 200, big.ToNearestEven)
+	require.NoError(t, err)
+	data := encode(t, in)
+	assert.Equal(t, []Token{{A: TokenString, Data: in.Text('p', 0)}}, tokens(t, data))
+
+	out := new(big.Float).SetPrec(200)
+	require.NoError(t, Unmarshal(data, out))
+	assert.Equal(t, 0, in.Cmp(out))
+
+	// a target without precision gets enough for the mantissa
+	third := new(big.Float).SetPrec(200).Quo(big.NewFloat(1), big.NewFloat(3))
+	var got big.Float
+	require.NoError(t, Unmarshal(encode(t, third), &got))
+	assert.Equal(t, 0, third.Cmp(&got))
+	assert.GreaterOrEqual(t, got.Prec(), third.MinPrec())
+
+	// short mantissas and decimal strings keep the default 64 bits
+	got = big.Float{}
+	require.NoError(t, Unmarshal(encode(t, big.NewFloat(3)), &got))
+	assert.Equal(t, "3", got.String())
+	assert.Equal(t, uint(64), got.Prec())
+	got = big.Float{}
+	require.NoError(t, Unmarshal(encode(t, "0.1"), &got))
+	assert.Equal(t, uint(64), got.Prec())
+
+	inf := new(big.Float).SetInf(true)
+	got = big.Float{}
+	require.NoError(t, Unmarshal(encode(t, inf), &got))
+	assert.True(t, got.IsInf() && got.Signbit())
+
+	require.NoError(t, Unmarshal(encode(t, 1.5), out))
+	assert.Equal(t, "1.5", out.String())
+	require.NoError(t, Unmarshal(encode(t, bigInt(t, "100000000000000000000")), out))
+	assert.Equal(t, "1e+20", out.String())
+}
@@ -0,0 +1,108 @@
+package muon
+
+import (
+	"fmt"
+	"io"
+
+	"ekyu.moe/leb128"
+)
+
+// blobChunkSize is the largest chunk WriteBlobFrom reads and writes at once.
+const blobChunkSize = 64 << 10
+
+// WriteBlobFrom writes the bytes read from r until io.EOF as a chunked
+// uint8 TypedArray (0x85 tag), one chunk per read, so blobs of any size are
+// written without holding them in memory. It returns the number of bytes
+// copied from r. Like [Encoder.WriteChunkedTypedArray] it ignores
+// DisableTypedArrays.
+//
+// Only writing streams. The result decodes into a []byte; [Reader.ReadBlobTo]
+// copies it to an io.Writer instead, but from the Reader's in-memory input.
+func (e *Encoder) WriteBlobFrom(w io.Writer, r io.Reader) (int64, error) {
+	if err := e.writeBytes(w, []byte{typedArrayChunk, typeUint8}); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, blobChunkSize)
+	var total int64
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			total += int64(n)
+			if err := e.flushScratch(w, leb128.AppendUleb128(e.scratch[:0], uint64(n))); err != nil {
+				return total, err
+			}
+			if err := e.writeBytes(w, buf[:n]); err != nil {
+				return total, err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	// terminating zero-length chunk
+	return total, e.writeByte(w, 0x00)
+}
+
+// ReadBlobTo reads the next value, which must be a uint8 TypedArray, plain
+// or chunked, and writes its bytes to w chunk by chunk instead of merging
+// them into one slice. It returns the number of bytes written. When the
+// next value is anything else, or is cut short, nothing is consumed and an
+// error is returned, so the caller can fall back to [Reader.Next].
+//
+// This does not stream: a Reader works on an in-memory []byte, so the chunks
+// are copied out of that buffer. Only the write side,
+// [Encoder.WriteBlobFrom], runs in constant memory. There is no counterpart
+// on [Decoder], [ListIter] or [DictIter], which read a blob as one merged
+// []byte.
+func (r *Reader) ReadBlobTo(w io.Writer) (int64, error) {
+	r.skipPadding()
+	if r.scanp+2 > len(r.in) {
+		return 0, io.EOF
+	}
+	first, typeByte := r.in[r.scanp], r.in[r.scanp+1]
+	if first != typedArray && first != typedArrayChunk {
+		return 0, fmt.Errorf("expected a uint8 TypedArray, got 0x%02X", first)
+	}
+	if typeByte != typeUint8 {
+		return 0, fmt.Errorf("expected a uint8 TypedArray, got element type 0x%02X", typeByte)
+	}
+
+	// find every chunk before consuming anything
+	var chunks [][]byte
+	p := r.scanp + 2
+	for {
+		count, n := leb128.DecodeUleb128(r.lebBytesAt(p))
+		if n == 0 || r.in[p+int(n)-1]&0x80 != 0 {
+			// no LEB128, or one cut off by the end of the input
+			return 0, io.EOF
+		}
+		p += int(n)
+		if first == typedArrayChunk && count == 0 {
+			break
+		}
+		end := p + int(count)
+		if end > len(r.in) || end < p {
+			return 0, io.EOF
+		}
+		chunks = append(chunks, r.in[p:end])
+		p = end
+		if first == typedArray {
+			break
+		}
+	}
+	r.scanp = p
+	r.lastType = first
+
+	var total int64
+	for _, chunk := range chunks {
+		written, err := w.Write(chunk)
+		total += int64(written)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,103 @@
+package muon
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBlobFrom(t *testing.T) {
+	blob := make([]byte, 3*blobChunkSize/2)
+	for i := range blob {
+		blob[i] = byte(i * 7)
+	}
+	var buf bytes.Buffer
+	n, err := (&Encoder{}).WriteBlobFrom(&buf, bytes.NewReader(blob))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(blob)), n)
+	assert.Equal(t, []byte{typedArrayChunk, typeUint8, 0x80, 0x80, 0x04}, buf.Bytes()[:5])
+
+	var out []byte
+	require.NoError(t, Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, blob, out)
+
+	t.Run("empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := (&Encoder{}).WriteBlobFrom(&buf, bytes.NewReader(nil))
+		require.NoError(t, err)
+		assert.Equal(t, []byte{typedArrayChunk, typeUint8, 0x00}, buf.Bytes())
+		assert.Equal(t, []Token{{A: TokenTypedArray, Data: []uint8{}}}, tokens(t, buf.Bytes()))
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		boom := errors.New("boom")
+		r := io.MultiReader(bytes.NewReader([]byte{1, 2}), iotest.ErrReader(boom))
+		n, err := (&Encoder{}).WriteBlobFrom(io.Discard, r)
+		assert.ErrorIs(t, err, boom)
+		assert.Equal(t, int64(2), n)
+	})
+}
+
+func TestReader_ReadBlobTo(t *testing.T) {
+	var buf bytes.Buffer
+	enc := &Encoder{}
+	require.NoError(t, enc.Write(&buf, "before"))
+	_, err := enc.WriteBlobFrom(&buf, iotest.OneByteReader(bytes.NewReader([]byte("firmware"))))
+	require.NoError(t, err)
+	require.NoError(t, enc.Write(&buf, []byte("plain")))
+	require.NoError(t, enc.Write(&buf, []int16{1}))
+
+	r := NewByteReader(buf.Bytes())
+	var blob bytes.Buffer
+
+	// anything but a uint8 TypedArray is left for Next
+	_, err = r.ReadBlobTo(&blob)
+	assert.Error(t, err)
+	tok, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, Token{A: TokenString, Data: "before"}, tok)
+
+	n, err := r.ReadBlobTo(&blob)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), n)
+	assert.Equal(t, "firmware", blob.String())
+
+	blob.Reset()
+	n, err = r.ReadBlobTo(&blob)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.Equal(t, "plain", blob.String())
+
+	_, err = r.ReadBlobTo(&blob)
+	assert.Error(t, err)
+	tok, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, Token{A: TokenTypedArray, Data: []int16{1}}, tok)
+
+	_, err = r.ReadBlobTo(&blob)
+	assert.Equal(t, io.EOF, err)
+
+	t.Run("truncated", func(t *testing.T) {
+		// nothing is written or consumed, in either form
+		for _, data := range [][]byte{
+			{typedArrayChunk, typeUint8, 0x02, 'a', 'b', 0x03, 'c'},
+			{typedArrayChunk, typeUint8, 0x02, 'a', 'b'},
+			{typedArray, typeUint8, 0x03, 'a'},
+			{typedArray, typeUint8},
+			{typedArray, typeUint8, 0x80},
+		} {
+			r := NewByteReader(data)
+			var blob bytes.Buffer
+			n, err := r.ReadBlobTo(&blob)
+			assert.Equal(t, io.EOF, err, "% x", data)
+			assert.Zero(t, n)
+			assert.Zero(t, blob.Len())
+			assert.Zero(t, r.scanp, "% x", data)
+		}
+	})
+}
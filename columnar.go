@@ -0,0 +1,245 @@
+package muon
+
+import (
+	"io"
+	"reflect"
+
+	"ekyu.moe/leb128"
+)
+
+// Columnar encoding writes a slice of flat structs as a dict mapping each
+// field name to a column holding that field of every element: a TypedArray
+// for numeric fields and a list for bool and string fields.
+//
+//	[]Sample{{T: 1, V: 0.5}, {T: 2, V: 0.7}}  →  {"t": [1 2], "v": [0.5 0.7]}
+//
+// A struct is flat when all its encoded fields are bool, integer, float or
+// string values that do not implement a marshaler interface. A type= tag
+// option sets the element type of the field's column.
+
+// column is one field of a struct encoded column-wise.
+type column struct {
+	field
+	typeByte byte // TypedArray element type; 0 for list columns
+	wire     bool // typeByte comes from a type= tag option
+}
+
+// columnarFields returns the columns of struct type t, or false when t is
+// not flat enough to be encoded column-wise.
+func columnarFields(t reflect.Type, n Naming) ([]column, bool) {
+	if t.Kind() != reflect.Struct || t == timeType || isMarshaler(t) || isMarshaler(reflect.PtrTo(t)) || structToArray(t) {
+		return nil, false
+	}
+	fields := structFields(t, n)
+	if len(fields) == 0 {
+		return nil, false
+	}
+	cols := make([]column, len(fields))
+	for i, f := range fields {
+		if f.info.Keyed || f.info.Err != nil {
+			return nil, false
+		}
+		ft := t
+		for _, x := range f.index {
+			if ft.Kind() != reflect.Struct {
+				// promoted through an embedded pointer
+				return nil, false
+			}
+			ft = ft.Field(x).Type
+		}
+		if ft == durationType || isMarshaler(ft) || isMarshaler(reflect.PtrTo(ft)) {
+			return nil, false
+		}
+		cols[i].field = f
+		if f.info.Type != "" {
+			// unknown or mismatched wire types are reported by the row encoder
+			tb, ok := wireTypes[f.info.Type]
+			if !ok || ft == float16Type || !wireTypeFits(ft.Kind(), tb) {
+				return nil, false
+			}
+			cols[i].typeByte, cols[i].wire = tb, true
+			continue
+		}
+		if ft == float16Type {
+			cols[i].typeByte = typeFloat16
+			continue
+		}
+		switch k := ft.Kind(); k {
+		case reflect.Bool, reflect.String:
+		case reflect.Int:
+			cols[i].typeByte = typeInt64
+		case reflect.Uint:
+			cols[i].typeByte = typeUint64
+		default:
+			tb, ok := elemKindToTypeByte[k]
+			if !ok {
+				return nil, false
+			}
+			cols[i].typeByte = tb
+		}
+	}
+	return cols, true
+}
+
+// newColumnarEncoder returns an encoder writing slices of elemType
+// column-wise, or nil when elemType is not flat.
+func newColumnarEncoder(elemType reflect.Type, n Naming) encoderFunc {
+	cols, ok := columnarFields(elemType, n)
+	if !ok {
+		return nil
+	}
+	return func(e *Encoder, w io.Writer, v reflect.Value) error {
+		if err := e.writeByte(w, dictStart); err != nil {
+			return err
+		}
+		for i := range cols {
+			if err := e.writeString(w, cols[i].name); err != nil {
+				return err
+			}
+			if err := e.writeColumn(w, v, &cols[i]); err != nil {
+				return err
+			}
+		}
+		return e.writeByte(w, dictEnd)
+	}
+}
+
+func (e *Encoder) writeColumn(w io.Writer, v reflect.Value, c *column) error {
+	count := v.Len()
+	if c.typeByte == 0 || c.typeByte == 0xBB || e.DisableTypedArrays {
+		if err := e.writeByte(w, listStart); err != nil {
+			return err
+		}
+		for i := 0; i < count; i++ {
+			fv := v.Index(i).FieldByIndex(c.index)
+			var err error
+			if c.wire {
+				err = e.writeWireTyped(w, fv, c.typeByte)
+			} else {
+				err = e.writeScalar(w, fv)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return e.writeByte(w, listEnd)
+	}
+
+	appendElem := appendTypedElem
+	if c.wire {
+		appendElem = appendWireTypedElem
+	}
+	b := append(e.scratch[:0], typedArray, c.typeByte)
+	b = leb128.AppendUleb128(b, uint64(count))
+	for i := 0; i < count; i++ {
+		var err error
+		if b, err = appendElem(b, v.Index(i).FieldByIndex(c.index), c.typeByte); err != nil {
+			return err
+		}
+		if len(b) >= typedArrayFlushSize {
+			if err := e.flushScratch(w, b); err != nil {
+				return err
+			}
+			b = e.scratch[:0]
+		}
+	}
+	return e.flushScratch(w, b)
+}
+
+// newColumnarDecoder returns a decoder for the dict written by a columnar
+// encoder into a slice of type t, or nil when its elements are not flat.
+// The slice is replaced by one as long as the longest column; unknown
+// columns are skipped.
+func newColumnarDecoder(t reflect.Type, n Naming) func(d *Decoder, v reflect.Value) error {
+	elemType := t.Elem()
+	cols, ok := columnarFields(elemType, n)
+	if !ok {
+		return nil
+	}
+	all := make([]field, len(cols))
+	plan := make([]*decodeField, len(cols))
+	for i, c := range cols {
+		all[i] = c.field
+		plan[i] = &decodeField{index: c.index, dec: typeDecoder(elemType.FieldByIndex(c.index).Type, n)}
+	}
+	fields := newFieldLookup(all, plan)
+
+	return func(d *Decoder, v reflect.Value) error {
+		v.Set(reflect.MakeSlice(t, 0, 0))
+		for {
+			keyTok, err := d.r.Next()
+			if err != nil {
+				return err
+			}
+			if keyTok.A == TokenDictEnd {
+				return nil
+			}
+			if keyTok.A != TokenString {
+				return errUnexpectedToken(keyTok.A)
+			}
+			name := keyTok.Data.(string)
+			f, ok := fields.find(d, name)
+			if !ok {
+				if d.DisallowUnknownFields {
+					return errUnknownField(name, elemType)
+				}
+				if err := d.skipValue(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.readColumn(v, f); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readColumn stores the next value, a TypedArray or list, into field f of
+// the elements of slice v, growing v as needed.
+func (d *Decoder) readColumn(v reflect.Value, f *decodeField) error {
+	tok, err := d.r.Next()
+	if err != nil {
+		return err
+	}
+	switch tok.A {
+	case TokenTypedArray:
+		src := reflect.ValueOf(tok.Data)
+		growSlice(v, src.Len())
+		for i := 0; i < src.Len(); i++ {
+			if err := setTypedElem(v.Index(i).FieldByIndex(f.index), src.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case TokenListStart:
+		for i := 0; ; i++ {
+			tok, err := d.r.Next()
+			if err != nil {
+				return err
+			}
+			if tok.A == TokenListEnd {
+				return nil
+			}
+			growSlice(v, i+1)
+			if err := f.dec(d, tok, v.Index(i).FieldByIndex(f.index)); err != nil {
+				return err
+			}
+		}
+	}
+	return errTypeMismatch(tok.A, v.Interface())
+}
+
+// growSlice extends slice v with zero elements to at least n.
+func growSlice(v reflect.Value, n int) {
+	if v.Len() >= n {
+		return
+	}
+	if v.Cap() >= n {
+		v.SetLen(n)
+		return
+	}
+	grown := reflect.MakeSlice(v.Type(), n, n+n/2)
+	reflect.Copy(grown, v)
+	v.Set(grown)
+}
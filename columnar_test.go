@@ -0,0 +1,145 @@
+package muon
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sample struct {
+	T     int64   `muon:"t"`
+	V     float64 `muon:"v"`
+	Count uint16
+	OK    bool   `muon:"ok"`
+	Tag   string `muon:"tag"`
+}
+
+func TestColumnar(t *testing.T) {
+	in := []sample{
+		{T: 1, V: 0.5, Count: 3, OK: true, Tag: "a"},
+		{T: 2, V: 0.75, Count: 4, Tag: "b"},
+	}
+	data := encodeWith(t, &Encoder{Columnar: true}, in)
+	toks := tokens(t, data)
+	assert.Equal(t, []Token{
+		{A: TokenDictStart},
+		{A: TokenString, Data: "t"}, {A: TokenTypedArray, Data: []int64{1, 2}},
+		{A: TokenString, Data: "v"}, {A: TokenTypedArray, Data: []float64{0.5, 0.75}},
+		{A: TokenString, Data: "count"}, {A: TokenTypedArray, Data: []uint16{3, 4}},
+		{A: TokenString, Data: "ok"}, {A: TokenListStart}, {A: TokenTrue}, {A: TokenFalse}, {A: TokenListEnd},
+		{A: TokenString, Data: "tag"}, {A: TokenListStart}, {A: TokenString, Data: "a"}, {A: TokenString, Data: "b"}, {A: TokenListEnd},
+		{A: TokenDictEnd},
+	}, toks)
+
+	var out []sample
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+
+	// the row form still decodes into the same type
+	out = nil
+	require.NoError(t, Unmarshal(encode(t, in), &out))
+	assert.Equal(t, in, out)
+
+	t.Run("empty", func(t *testing.T) {
+		data := encodeWith(t, &Encoder{Columnar: true}, []sample{})
+		out := []sample{{T: 9}}
+		require.NoError(t, Unmarshal(data, &out))
+		assert.Empty(t, out)
+	})
+}
+
+func TestColumnar_Smaller(t *testing.T) {
+	in := make([]sample, 1000)
+	for i := range in {
+		in[i] = sample{T: int64(i), V: float64(i) / 3, Count: uint16(i), Tag: "x"}
+	}
+	rows := encode(t, in)
+	cols := encodeWith(t, &Encoder{Columnar: true}, in)
+	assert.Less(t, len(cols), len(rows))
+}
+
+func TestColumnar_Tag(t *testing.T) {
+	type Series struct {
+		Name    string   `muon:"name"`
+		Samples []sample `muon:"samples,columnar"`
+		Rows    []sample `muon:"rows"`
+	}
+	in := Series{Name: "s", Samples: []sample{{T: 1}, {T: 2}}, Rows: []sample{{T: 3}}}
+	data := encode(t, in)
+	toks := tokens(t, data)
+	assert.Equal(t, Token{A: TokenString, Data: "samples"}, toks[3])
+	assert.Equal(t, Token{A: TokenDictStart}, toks[4])
+
+	var out Series
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+}
+
+func TestColumnar_WireType(t *testing.T) {
+	type Reading struct {
+		V     float64 `muon:"v,type=f32"`
+		Level int     `muon:"level,type=u8"`
+		Seq   uint64  `muon:"seq,type=leb"`
+	}
+	in := []Reading{{V: 0.5, Level: 3, Seq: 1}, {V: 0.25, Level: 200, Seq: 1 << 40}}
+	data := encodeWith(t, &Encoder{Columnar: true}, in)
+	assert.Equal(t, []Token{
+		{A: TokenDictStart},
+		{A: TokenString, Data: "v"}, {A: TokenTypedArray, Data: []float32{0.5, 0.25}},
+		{A: TokenString, Data: "level"}, {A: TokenTypedArray, Data: []uint8{3, 200}},
+		{A: TokenString, Data: "seq"}, {A: TokenListStart}, {A: TokenInt, Data: 1}, {A: TokenInt, Data: 1 << 40}, {A: TokenListEnd},
+		{A: TokenDictEnd},
+	}, tokens(t, data))
+
+	var out []Reading
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+
+	// under DisableTypedArrays the column is a list of numbers of the wire type
+	data = encodeWith(t, &Encoder{Columnar: true, DisableTypedArrays: true}, in)
+	assert.Equal(t, []byte{listStart, 0xB9}, data[3:5])
+	out = nil
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+
+	assert.Error(t, (&Encoder{Columnar: true}).Write(io.Discard, []Reading{{Level: 300}}))
+
+	// a wire type that does not fit the field falls back to rows, which report it
+	type Bad struct {
+		S string `muon:"s,type=f32"`
+	}
+	assert.Error(t, (&Encoder{Columnar: true}).Write(io.Discard, []Bad{{S: "x"}}))
+}
+
+func TestColumnar_NotFlat(t *testing.T) {
+	type Nested struct {
+		Tags []string `muon:"tags"`
+	}
+	in := []Nested{{Tags: []string{"a"}}}
+	// falls back to a list of dicts
+	assert.Equal(t, encode(t, in), encodeWith(t, &Encoder{Columnar: true}, in))
+}
+
+func TestColumnar_Decode(t *testing.T) {
+	t.Run("ragged columns", func(t *testing.T) {
+		data := encode(t, map[string]interface{}{
+			"t":   []int32{1, 2, 3},
+			"tag": []interface{}{"a"},
+			"x":   []interface{}{"skipped"},
+		})
+		var out []sample
+		require.NoError(t, Unmarshal(data, &out))
+		assert.Equal(t, []sample{{T: 1, Tag: "a"}, {T: 2}, {T: 3}}, out)
+
+		d := NewDecoder(data)
+		d.DisallowUnknownFields = true
+		requireMuonError(t, d.Unmarshal(&out), ErrCodeUnknownField)
+	})
+	t.Run("mismatch", func(t *testing.T) {
+		var out []sample
+		require.Error(t, Unmarshal(encode(t, map[string]interface{}{"tag": []int64{1}}), &out))
+		require.Error(t, Unmarshal(encode(t, map[string]interface{}{"t": "x"}), &out))
+	})
+}
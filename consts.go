@@ -27,6 +27,8 @@ const (
 	stringRef       = 0x81
 	typedArray      = 0x84
 	typedArrayChunk = 0x85
+	floatF16        = 0xB8
+	floatF32        = 0xB9
 	floatF64        = 0xBA
 
 	// magic signature bytes (after the 0x8F tag byte)
@@ -41,6 +43,7 @@ const (
 	typeUint16  = 0xB5
 	typeUint32  = 0xB6
 	typeUint64  = 0xB7
+	typeFloat16 = 0xB8 // same as floatF16
 	typeFloat32 = 0xB9
 	typeFloat64 = 0xBA // same as floatF64
 )
@@ -56,6 +59,7 @@ const (
 	TypeByteUint16  byte = typeUint16
 	TypeByteUint32  byte = typeUint32
 	TypeByteUint64  byte = typeUint64
+	TypeByteFloat16 byte = typeFloat16
 	TypeByteFloat32 byte = typeFloat32
 	TypeByteFloat64 byte = typeFloat64
 )
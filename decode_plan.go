@@ -0,0 +1,577 @@
+package muon
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// decoderFunc stores the value starting with tok into v, a settable value of
+// the type the function was compiled for. Decoder options are read from d at
+// call time, so one compiled function serves every Decoder.
+type decoderFunc func(d *Decoder, tok Token, v reflect.Value) error
+
+// decoderCache maps planKey → decoderFunc. Entries are built once, on first
+// use of a type, and shared by all decoders.
+var decoderCache sync.Map
+
+// typeDecoder returns the compiled decoder for t under naming strategy n.
+func typeDecoder(t reflect.Type, n Naming) decoderFunc {
+	key := planKey{t, n}
+	if fi, ok := decoderCache.Load(key); ok {
+		return fi.(decoderFunc)
+	}
+
+	// Store a forwarding func first so that recursive types resolve to it
+	// while the real decoder is being built.
+	var (
+		wg sync.WaitGroup
+		f  decoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := decoderCache.LoadOrStore(key, decoderFunc(func(d *Decoder, tok Token, v reflect.Value) error {
+		wg.Wait()
+		return f(d, tok, v)
+	}))
+	if loaded {
+		return fi.(decoderFunc)
+	}
+
+	f = withTransparentTokens(t, newTypeDecoder(t, n))
+	wg.Done()
+	decoderCache.Store(key, f)
+	return f
+}
+
+// withTransparentTokens wraps dec so that magic and count tags are skipped
+// and nil zeroes the target, whatever its type.
+func withTransparentTokens(t reflect.Type, dec decoderFunc) decoderFunc {
+	zero := reflect.Zero(t)
+	return func(d *Decoder, tok Token, v reflect.Value) error {
+		for tok.A == TokenMagic || tok.A == TokenCount {
+			var err error
+			if tok, err = d.r.Next(); err != nil {
+				return err
+			}
+		}
+		if tok.A == TokenNil {
+			v.Set(zero)
+			return nil
+		}
+		return dec(d, tok, v)
+	}
+}
+
+func newTypeDecoder(t reflect.Type, n Naming) decoderFunc {
+	if t == timeType || t == durationType {
+		return func(d *Decoder, tok Token, v reflect.Value) error {
+			return d.unmarshalTime(tok, v, d.TimeFormat)
+		}
+	}
+
+	if t == numberType {
+		return numberDecoder
+	}
+	if t == float16Type {
+		return float16Decoder
+	}
+	if t == dictType {
+		return dictDecoder
+	}
+	if isBigType(t) {
+		return func(d *Decoder, tok Token, v reflect.Value) error {
+			return d.unmarshalBig(tok, v)
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return newPtrDecoder(t, n)
+	case reflect.Interface:
+		return interfaceDecoder
+	}
+
+	dec := newKindDecoder(t, n)
+	if pt := reflect.PtrTo(t); pt.Implements(textUnmarshalerType) || pt.Implements(binaryUnmarshalerType) {
+		return newEncodingDecoder(dec)
+	}
+	return dec
+}
+
+var (
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// newEncodingDecoder hands strings to an [encoding.TextUnmarshaler] target and
+// uint8 TypedArrays to an [encoding.BinaryUnmarshaler] target; other tokens,
+// and targets that are not addressable, go to dec.
+func newEncodingDecoder(dec decoderFunc) decoderFunc {
+	return func(d *Decoder, tok Token, v reflect.Value) error {
+		if !v.CanAddr() {
+			return dec(d, tok, v)
+		}
+		p := v.Addr().Interface()
+		tu, isText := p.(encoding.TextUnmarshaler)
+		bu, isBinary := p.(encoding.BinaryUnmarshaler)
+		switch {
+		case tok.A == TokenString && isText:
+			return tu.UnmarshalText([]byte(tok.Data.(string)))
+		case tok.A == TokenString && isBinary:
+			return bu.UnmarshalBinary([]byte(tok.Data.(string)))
+		case tok.A == TokenTypedArray && isBinary:
+			data, ok := tok.Data.([]uint8)
+			if !ok {
+				return errTypeMismatch(tok.A, v.Interface())
+			}
+			return bu.UnmarshalBinary(data)
+		}
+		return dec(d, tok, v)
+	}
+}
+
+func newPtrDecoder(t reflect.Type, n Naming) decoderFunc {
+	elemType := t.Elem()
+	elemDec := typeDecoder(elemType, n)
+	return func(d *Decoder, tok Token, v reflect.Value) error {
+		if v.IsNil() {
+			v.Set(reflect.New(elemType))
+		}
+		return elemDec(d, tok, v.Elem())
+	}
+}
+
+// interfaceDecoder builds the value with the schema-less Decode path.
+func interfaceDecoder(d *Decoder, tok Token, v reflect.Value) error {
+	val, err := d.tokenToValue(tok)
+	if err != nil {
+		return err
+	}
+	if val == nil {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		v.Set(reflect.ValueOf(val))
+	}
+	return nil
+}
+
+// newKindDecoder returns the decoder for the container kinds, whose element
+// decoders are resolved up front, and scalarDecoder for everything else.
+func newKindDecoder(t reflect.Type, n Naming) decoderFunc {
+	var onList, onDict func(d *Decoder, v reflect.Value) error
+	switch t.Kind() {
+	case reflect.Slice:
+		onList = newSliceDecoder(t, n)
+		onDict = newColumnarDecoder(t, n)
+	case reflect.Array:
+		onList = newArrayDecoder(t, n)
+	case reflect.Struct:
+		onDict = newStructDecoder(t, n)
+		if structToArray(t) {
+			onList = newPositionalDecoder(t, n)
+		}
+	case reflect.Map:
+		onDict = newMapDecoder(t, n)
+	default:
+		return scalarDecoder
+	}
+	return func(d *Decoder, tok Token, v reflect.Value) error {
+		switch {
+		case tok.A == TokenListStart && onList != nil:
+			return onList(d, v)
+		case tok.A == TokenDictStart && onDict != nil:
+			return onDict(d, v)
+		}
+		return scalarDecoder(d, tok, v)
+	}
+}
+
+// scalarDecoder stores a single-token value into v, or reports why it cannot.
+func scalarDecoder(d *Decoder, tok Token, v reflect.Value) error {
+	switch tok.A {
+	case TokenTrue, TokenFalse:
+		return d.unmarshalBool(tok, v)
+	case TokenInt:
+		return d.unmarshalInt(tok, v)
+	case TokenFloat:
+		return d.unmarshalFloat(tok, v)
+	case TokenString:
+		return d.unmarshalString(tok, v)
+	case TokenTypedArray:
+		return d.unmarshalTypedArray(tok, v)
+	case TokenListStart, TokenDictStart:
+		return errTypeMismatch(tok.A, v.Interface())
+	}
+	return errUnexpectedToken(tok.A)
+}
+
+func newSliceDecoder(t reflect.Type, n Naming) func(d *Decoder, v reflect.Value) error {
+	return sliceDecoder(t, typeDecoder(t.Elem(), n))
+}
+
+// sliceDecoder returns the list decoder for slice type t that reads each
+// element with elemDec.
+func sliceDecoder(t reflect.Type, elemDec decoderFunc) func(d *Decoder, v reflect.Value) error {
+	elemZero := reflect.Zero(t.Elem())
+	return func(d *Decoder, v reflect.Value) error {
+		for {
+			tok, err := d.r.Next()
+			if err != nil {
+				return err
+			}
+			if tok.A == TokenListEnd {
+				return nil
+			}
+			n := v.Len()
+			v.Set(reflect.Append(v, elemZero))
+			if err := elemDec(d, tok, v.Index(n)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func newArrayDecoder(t reflect.Type, n Naming) func(d *Decoder, v reflect.Value) error {
+	return arrayDecoder(t, typeDecoder(t.Elem(), n))
+}
+
+// arrayDecoder returns the list decoder for array type t that reads each
+// element with elemDec.
+func arrayDecoder(t reflect.Type, elemDec decoderFunc) func(d *Decoder, v reflect.Value) error {
+	return func(d *Decoder, v reflect.Value) error {
+		for i := 0; ; i++ {
+			tok, err := d.r.Next()
+			if err != nil {
+				return err
+			}
+			if tok.A == TokenListEnd {
+				if i < v.Len() && d.StrictArrayLength {
+					return errArrayTooShort(t, i)
+				}
+				return nil
+			}
+			if i >= v.Len() && d.StrictArrayLength {
+				return errArrayTooLong(t)
+			}
+			if i < v.Len() {
+				if err := elemDec(d, tok, v.Index(i)); err != nil {
+					return err
+				}
+			} else if err := d.skipValueFrom(tok); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func newMapDecoder(t reflect.Type, n Naming) func(d *Decoder, v reflect.Value) error {
+	keyType, elemType := t.Key(), t.Elem()
+	keyDec, elemDec := typeDecoder(keyType, n), typeDecoder(elemType, n)
+	keyZero, elemZero := reflect.Zero(keyType), reflect.Zero(elemType)
+	return func(d *Decoder, v reflect.Value) error {
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		key := reflect.New(keyType).Elem()
+		elem := reflect.New(elemType).Elem()
+		intKeyType := byte(0)
+		var seen map[interface{}]struct{}
+		if d.RejectDuplicateKeys {
+			seen = map[interface{}]struct{}{}
+		}
+
+		for first := true; ; first = false {
+			var keyTok Token
+			var err error
+			if !first && intKeyType != 0 {
+				keyTok, err = d.r.NextIntKey(intKeyType)
+			} else {
+				keyTok, err = d.r.Next()
+			}
+			if err != nil {
+				return err
+			}
+			if keyTok.A == TokenDictEnd {
+				return nil
+			}
+
+			// remember int key type for subsequent keys
+			if keyTok.A == TokenInt && intKeyType == 0 {
+				intKeyType = d.r.lastIntKeyType
+			}
+
+			key.Set(keyZero)
+			if err := keyDec(d, keyTok, key); err != nil {
+				return err
+			}
+			if seen != nil {
+				k := key.Interface()
+				if _, dup := seen[k]; dup {
+					return errDuplicateKey(k)
+				}
+				seen[k] = struct{}{}
+			}
+
+			valTok, err := d.r.Next()
+			if err != nil {
+				return err
+			}
+			elem.Set(elemZero)
+			if err := elemDec(d, valTok, elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	}
+}
+
+// decodeField is a struct field in a compiled struct decoder.
+type decodeField struct {
+	index []int
+	dec   decoderFunc
+}
+
+func newStructDecoder(t reflect.Type, n Naming) func(d *Decoder, v reflect.Value) error {
+	all := structFields(t, n)
+	for _, f := range all {
+		if f.info.Err != nil {
+			err := errInvalidTarget(fmt.Sprintf("struct %s: field %s: %v", t, f.name, f.info.Err))
+			return func(d *Decoder, v reflect.Value) error {
+				return err
+			}
+		}
+	}
+	plan := make([]*decodeField, len(all))
+	for i, f := range all {
+		plan[i] = &decodeField{
+			index: f.index,
+			dec:   newFieldDecoder(t.FieldByIndex(f.index).Type, f, n),
+		}
+	}
+	fields := newFieldLookup(all, plan)
+
+	// integer keys of keyed fields
+	var keyed map[int64]*decodeField
+	for i, f := range all {
+		if f.info.Keyed {
+			if keyed == nil {
+				keyed = map[int64]*decodeField{}
+			}
+			if _, taken := keyed[f.info.Key]; !taken {
+				keyed[f.info.Key] = plan[i]
+			}
+		}
+	}
+
+	return func(d *Decoder, v reflect.Value) error {
+		var seen map[interface{}]struct{}
+		if d.RejectDuplicateKeys {
+			seen = map[interface{}]struct{}{}
+		}
+		intKeyType := byte(0)
+		for first := true; ; first = false {
+			var keyTok Token
+			var err error
+			if intKeyType != 0 {
+				keyTok, err = d.r.NextIntKey(intKeyType)
+			} else {
+				keyTok, err = d.r.Next()
+			}
+			if err != nil {
+				return err
+			}
+			if keyTok.A == TokenDictEnd {
+				return nil
+			}
+
+			var key interface{}
+			var f *decodeField
+			var ok bool
+			switch {
+			case keyTok.A == TokenString && intKeyType == 0:
+				name := keyTok.Data.(string)
+				key = name
+				f, ok = fields.find(d, name)
+			case keyTok.A == TokenInt && keyed != nil && (first || intKeyType != 0):
+				// remember int key type for subsequent keys
+				intKeyType = d.r.lastIntKeyType
+				n, err := toInt64(keyTok.Data)
+				if err != nil {
+					return err
+				}
+				key = n
+				f, ok = keyed[n]
+			default:
+				return errUnexpectedToken(keyTok.A)
+			}
+			if seen != nil {
+				if _, dup := seen[key]; dup {
+					return errDuplicateKey(key)
+				}
+				seen[key] = struct{}{}
+			}
+
+			if !ok {
+				if d.DisallowUnknownFields {
+					return errUnknownField(key, t)
+				}
+				// unknown field: read and discard the value
+				if err := d.skipValue(); err != nil {
+					return err
+				}
+				continue
+			}
+			valTok, err := d.r.Next()
+			if err != nil {
+				return err
+			}
+			var fv reflect.Value
+			if len(f.index) == 1 {
+				fv = v.Field(f.index[0])
+			} else {
+				fv = fieldByIndexAlloc(v, f.index)
+			}
+			if err := f.dec(d, valTok, fv); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// fieldLookup finds the decodeField for a dict key by field name or alias.
+type fieldLookup struct {
+	byName map[string]*decodeField
+	folded map[string]*decodeField // lower-cased keys, for CaseInsensitive
+}
+
+// newFieldLookup indexes plan, whose entries belong to the fields in all.
+// Aliases never shadow a field name.
+func newFieldLookup(all []field, plan []*decodeField) fieldLookup {
+	l := fieldLookup{
+		byName: make(map[string]*decodeField, len(all)),
+		folded: make(map[string]*decodeField, len(all)),
+	}
+	for i, f := range all {
+		l.byName[f.name] = plan[i]
+	}
+	for i, f := range all {
+		for _, a := range f.info.Aliases {
+			if _, taken := l.byName[a]; !taken {
+				l.byName[a] = plan[i]
+			}
+		}
+	}
+	// field names first, then aliases
+	for _, f := range all {
+		if k := strings.ToLower(f.name); l.folded[k] == nil {
+			l.folded[k] = l.byName[f.name]
+		}
+	}
+	for _, f := range all {
+		for _, a := range f.info.Aliases {
+			if k := strings.ToLower(a); l.folded[k] == nil {
+				l.folded[k] = l.byName[a]
+			}
+		}
+	}
+	return l
+}
+
+func (l fieldLookup) find(d *Decoder, name string) (*decodeField, bool) {
+	f, ok := l.byName[name]
+	if !ok && d.CaseInsensitive {
+		f, ok = l.folded[strings.ToLower(name)]
+	}
+	return f, ok
+}
+
+// newPositionalDecoder reads a list written by a toarray struct encoder into
+// the fields of t in order. For schema evolution, missing trailing values
+// leave their fields untouched and extra values are skipped.
+func newPositionalDecoder(t reflect.Type, n Naming) func(d *Decoder, v reflect.Value) error {
+	fields := structFields(t, n)
+	plan := make([]decodeField, len(fields))
+	for i, f := range fields {
+		plan[i] = decodeField{
+			index: f.index,
+			dec:   newFieldDecoder(t.FieldByIndex(f.index).Type, f, n),
+		}
+	}
+	return func(d *Decoder, v reflect.Value) error {
+		for i := 0; ; i++ {
+			tok, err := d.r.Next()
+			if err != nil {
+				return err
+			}
+			if tok.A == TokenListEnd {
+				return nil
+			}
+			if i >= len(plan) {
+				if err := d.skipValueFrom(tok); err != nil {
+					return err
+				}
+				continue
+			}
+			if tok.A == TokenNil {
+				// don't allocate a nil embedded pointer just to zero a field
+				if fv, ok := fieldByIndex(v, plan[i].index); ok {
+					fv.Set(reflect.Zero(fv.Type()))
+				}
+				continue
+			}
+			if err := plan[i].dec(d, tok, fieldByIndexAlloc(v, plan[i].index)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// newFieldDecoder returns the decoder for a struct field of type t, applying
+// the time format selected by its tag, also to the elements of a slice or
+// array of time values.
+func newFieldDecoder(t reflect.Type, f field, n Naming) decoderFunc {
+	format, ok := timeTagFormats[f.info.Time]
+	if !ok {
+		return typeDecoder(t, n)
+	}
+	base := t
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	timeDec := decoderFunc(func(d *Decoder, tok Token, v reflect.Value) error {
+		return d.unmarshalTime(tok, v, format)
+	})
+	var dec decoderFunc
+	switch {
+	case isTimeType(base):
+		dec = timeDec
+	case (base.Kind() == reflect.Slice || base.Kind() == reflect.Array) && isTimeType(base.Elem()):
+		elemDec := withTransparentTokens(base.Elem(), timeDec)
+		onList := sliceDecoder(base, elemDec)
+		if base.Kind() == reflect.Array {
+			onList = arrayDecoder(base, elemDec)
+		}
+		fallback := typeDecoder(base, n)
+		dec = func(d *Decoder, tok Token, v reflect.Value) error {
+			if tok.A == TokenListStart {
+				return onList(d, v)
+			}
+			return fallback(d, tok, v)
+		}
+	default:
+		return typeDecoder(t, n)
+	}
+	fieldType := t
+	for ; t.Kind() == reflect.Ptr; t = t.Elem() {
+		elemType, elemDec := t.Elem(), dec
+		dec = func(d *Decoder, tok Token, v reflect.Value) error {
+			if v.IsNil() {
+				v.Set(reflect.New(elemType))
+			}
+			return elemDec(d, tok, v.Elem())
+		}
+	}
+	return withTransparentTokens(fieldType, dec)
+}
@@ -1,11 +1,57 @@
 package muon
 
-import "io"
+import (
+	"io"
+	"math"
+)
 
 // Decoder reconstructs complete Go values from a muon byte stream.
 // Handles multiple concatenated objects (chaining) — call Decode in a loop
 // until io.EOF is returned.
 type Decoder struct {
+	// TimeFormat tells [Decoder.Unmarshal] how to read integers into
+	// time.Time and time.Duration targets; see [TimeFormat]. Struct fields can
+	// override it with the rfc3339, unixnano or unixms tag options.
+	TimeFormat TimeFormat
+	// Naming derives the expected dict key of struct fields without a name
+	// in their muon tag; it should match the Naming of the [Encoder] that
+	// wrote the data.
+	Naming Naming
+	// CaseInsensitive lets dict keys match struct field names and aliases
+	// regardless of case when there is no exact match.
+	CaseInsensitive bool
+	// DisallowUnknownFields makes [Decoder.Unmarshal] fail with
+	// [ErrCodeUnknownField] on dict keys that match no struct field, instead
+	// of skipping them.
+	DisallowUnknownFields bool
+	// StrictArrayLength makes [Decoder.Unmarshal] fail with
+	// [ErrCodeArrayLength] when a list or TypedArray decoded into a Go array
+	// has more or fewer elements than the array, instead of dropping extra
+	// elements and leaving missing ones untouched.
+	StrictArrayLength bool
+	// RejectTrailingData makes [Decoder.Unmarshal] fail with
+	// [ErrCodeTrailingData] when anything but padding follows the value.
+	// Leave it off to read a stream of concatenated values.
+	RejectTrailingData bool
+	// RejectDuplicateKeys makes [Decoder.Unmarshal] and [Decoder.Decode] fail
+	// with [ErrCodeDuplicateKey] when a dict repeats a key, instead of keeping
+	// the last value.
+	RejectDuplicateKeys bool
+	// IntFormat selects the Go type of integers returned by [Decoder.Decode]
+	// and stored into interface{} targets; see [IntFormat].
+	IntFormat IntFormat
+	// Float32 returns float16 and float32 values from [Decoder.Decode] as
+	// float32 instead of float64, keeping the precision they were written
+	// with visible.
+	Float32 bool
+	// Int64Keys returns integer-keyed dicts from [Decoder.Decode] as
+	// map[int64]interface{} instead of map[interface{}]interface{}. Keys
+	// that do not fit in an int64 fail with [ErrCodeTypeMismatch].
+	Int64Keys bool
+	// OrderedDicts returns dicts from [Decoder.Decode] as [Dict], keeping
+	// the order of their keys, instead of as Go maps.
+	OrderedDicts bool
+
 	r Reader
 }
 
@@ -34,9 +80,12 @@ func (d *Decoder) tokenToValue(tok Token) (interface{}, error) {
 		return tok.Data.(string), nil
 
 	case TokenInt:
-		return tok.Data, nil
+		return d.intValue(tok), nil
 
 	case TokenFloat:
+		if d.Float32 && (d.r.lastType == floatF16 || d.r.lastType == floatF32) {
+			return float32(tok.Data.(float64)), nil
+		}
 		return tok.Data.(float64), nil
 
 	case TokenTrue:
@@ -81,6 +130,9 @@ func (d *Decoder) readList() ([]interface{}, error) {
 }
 
 func (d *Decoder) readDict() (interface{}, error) {
+	if d.OrderedDicts {
+		return d.readOrderedDict()
+	}
 	// peek at first key to decide string vs integer dict
 	keyTok, err := d.r.Next()
 	if err != nil {
@@ -94,6 +146,9 @@ func (d *Decoder) readDict() (interface{}, error) {
 		return d.readStringDict(keyTok)
 	}
 	if keyTok.A == TokenInt {
+		if d.Int64Keys {
+			return d.readInt64Dict(keyTok)
+		}
 		return d.readIntDict(keyTok)
 	}
 	return nil, io.ErrUnexpectedEOF
@@ -104,6 +159,9 @@ func (d *Decoder) readStringDict(firstKey Token) (map[string]interface{}, error)
 	keyTok := firstKey
 	for {
 		key := keyTok.Data.(string)
+		if _, dup := out[key]; dup && d.RejectDuplicateKeys {
+			return nil, errDuplicateKey(key)
+		}
 		valTok, err := d.r.Next()
 		if err != nil {
 			return nil, err
@@ -129,7 +187,10 @@ func (d *Decoder) readIntDict(firstKey Token) (map[interface{}]interface{}, erro
 	intKeyType := d.r.lastIntKeyType
 	keyTok := firstKey
 	for {
-		key := keyTok.Data
+		key := d.intValue(keyTok)
+		if _, dup := out[key]; dup && d.RejectDuplicateKeys {
+			return nil, errDuplicateKey(key)
+		}
 		valTok, err := d.r.Next()
 		if err != nil {
 			return nil, err
@@ -150,3 +211,35 @@ func (d *Decoder) readIntDict(firstKey Token) (map[interface{}]interface{}, erro
 		}
 	}
 }
+
+func (d *Decoder) readInt64Dict(firstKey Token) (map[int64]interface{}, error) {
+	out := make(map[int64]interface{})
+	intKeyType := d.r.lastIntKeyType
+	keyTok := firstKey
+	for {
+		key, err := toInt64(keyTok.Data)
+		if n, ok := keyTok.Data.(uint64); err != nil || ok && n > math.MaxInt64 {
+			return nil, errKeyOverflow(keyTok.Data)
+		}
+		if _, dup := out[key]; dup && d.RejectDuplicateKeys {
+			return nil, errDuplicateKey(key)
+		}
+		valTok, err := d.r.Next()
+		if err != nil {
+			return nil, err
+		}
+		val, err := d.tokenToValue(valTok)
+		if err != nil {
+			return nil, err
+		}
+		out[key] = val
+
+		keyTok, err = d.r.NextIntKey(intKeyType)
+		if err != nil {
+			return nil, err
+		}
+		if keyTok.A == TokenDictEnd {
+			return out, nil
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package muon
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+)
+
+// Dict is a muon dict that keeps its entries in order. The [Encoder] writes
+// a Dict in stored order, also under Deterministic, and [Decoder.Decode]
+// returns dicts as Dict when [Decoder.OrderedDicts] is set, so a document
+// can be decoded, edited and written again without reordering its keys.
+// [Decoder.Unmarshal] fills a Dict target whatever that option; dicts nested
+// in its values follow it.
+//
+// Keys are strings or integers (any Go integer type, [Number] or *big.Int),
+// not both in one Dict. Integer keys are matched by value, so Get(1) finds
+// a key decoded as int64(1).
+type Dict []DictEntry
+
+// DictEntry is one key/value pair of a [Dict].
+type DictEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+var dictType = reflect.TypeOf(Dict(nil))
+
+// Index returns the position of key in d, or -1 if it is missing.
+func (d Dict) Index(key interface{}) int {
+	for i, e := range d {
+		if sameKey(e.Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the value stored under key and whether it was found.
+func (d Dict) Get(key interface{}) (interface{}, bool) {
+	if i := d.Index(key); i >= 0 {
+		return d[i].Value, true
+	}
+	return nil, false
+}
+
+// Set replaces the value stored under key, keeping its position, or appends
+// a new entry.
+func (d *Dict) Set(key, value interface{}) {
+	if i := d.Index(key); i >= 0 {
+		(*d)[i].Value = value
+		return
+	}
+	*d = append(*d, DictEntry{Key: key, Value: value})
+}
+
+// Delete removes key from d, keeping the order of the other entries, and
+// reports whether it was present.
+func (d *Dict) Delete(key interface{}) bool {
+	i := d.Index(key)
+	if i < 0 {
+		return false
+	}
+	*d = append((*d)[:i], (*d)[i+1:]...)
+	return true
+}
+
+// Keys returns the keys of d in order.
+func (d Dict) Keys() []interface{} {
+	keys := make([]interface{}, len(d))
+	for i, e := range d {
+		keys[i] = e.Key
+	}
+	return keys
+}
+
+// sameKey reports whether a and b are the same dict key: equal strings or
+// integers of equal value.
+func sameKey(a, b interface{}) bool {
+	if sa, ok := a.(string); ok {
+		sb, ok := b.(string)
+		return ok && sa == sb
+	}
+	x, okA := keyInt(a)
+	y, okB := keyInt(b)
+	return okA && okB && x.Cmp(y) == 0
+}
+
+// keyInt returns the value of an integer dict key.
+func keyInt(k interface{}) (*big.Int, bool) {
+	switch v := k.(type) {
+	case Number:
+		return v.BigInt(), true
+	case *big.Int:
+		return v, v != nil
+	}
+	rv := reflect.ValueOf(k)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return big.NewInt(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Int).SetUint64(rv.Uint()), true
+	}
+	return nil, false
+}
+
+// fixedKey returns k as a value of a fixed-size Go integer type, or false
+// when k has no fixed size.
+func fixedKey(k interface{}) (reflect.Value, bool) {
+	if n, ok := k.(Number); ok {
+		if n.Type < typeInt8 || n.Type > typeUint64 {
+			return reflect.Value{}, false
+		}
+		if n.v == nil {
+			return reflect.Zero(typedArrayElemTypes[n.Type]), true
+		}
+		return reflect.ValueOf(n.v).Convert(typedArrayElemTypes[n.Type]), true
+	}
+	rv := reflect.ValueOf(k)
+	switch rv.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv, true
+	}
+	return reflect.Value{}, false
+}
+
+// sortDict sorts d by key: strings in byte order, integers by value.
+func sortDict(d Dict) error {
+	ints := make([]*big.Int, len(d))
+	for i, entry := range d {
+		if _, ok := entry.Key.(string); ok {
+			continue
+		}
+		x, ok := keyInt(entry.Key)
+		if !ok {
+			return fmt.Errorf("dict keys must be string or integer, got %T", entry.Key)
+		}
+		ints[i] = x
+	}
+	order := make([]int, len(d))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if ints[a] != nil && ints[b] != nil {
+			return ints[a].Cmp(ints[b]) < 0
+		}
+		sa, _ := d[a].Key.(string)
+		sb, _ := d[b].Key.(string)
+		return sa < sb
+	})
+	sorted := make(Dict, len(d))
+	for i, j := range order {
+		sorted[i] = d[j]
+	}
+	copy(d, sorted)
+	return nil
+}
+
+func dictEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	return e.writeDict(w, v.Interface().(Dict), false)
+}
+
+// writeDict writes d in stored order. Integer keys keep their fixed-size
+// form when all of them share one Go type (or [Number] type byte) and are
+// written as SLEB128 otherwise.
+//
+// Keys after the first have no type byte, so an integer key that would read
+// back as the dict end or as padding (such as 147, or 127 in SLEB128) can
+// only be first. With lead, d is reordered to put it there; otherwise, and
+// when there are two of them, it is an error.
+func (e *Encoder) writeDict(w io.Writer, d Dict, lead bool) error {
+	if err := e.writeByte(w, dictStart); err != nil {
+		return err
+	}
+	if len(d) == 0 {
+		return e.writeByte(w, dictEnd)
+	}
+
+	_, isString := d[0].Key.(string)
+	fixed := !isString
+	var fixedType reflect.Type
+	for _, entry := range d {
+		if _, ok := entry.Key.(string); ok != isString {
+			return fmt.Errorf("mixed dict key types: %T and %T", d[0].Key, entry.Key)
+		}
+		if isString {
+			continue
+		}
+		if _, ok := keyInt(entry.Key); !ok {
+			return fmt.Errorf("dict keys must be string or integer, got %T", entry.Key)
+		}
+		if rv, ok := fixedKey(entry.Key); !ok || fixedType != nil && rv.Type() != fixedType {
+			fixed = false
+		} else {
+			fixedType = rv.Type()
+		}
+	}
+
+	if !isString {
+		if err := leadIntKey(d, fixed, lead); err != nil {
+			return err
+		}
+	}
+
+	for i, entry := range d {
+		var err error
+		switch {
+		case isString:
+			err = e.writeString(w, entry.Key.(string))
+		case fixed:
+			rv, _ := fixedKey(entry.Key)
+			err = e.writeDictIntKey(w, rv, i == 0)
+		default:
+			b := e.scratch[:0]
+			if i == 0 {
+				b = append(b, 0xBB)
+			}
+			x, _ := keyInt(entry.Key)
+			err = e.flushScratch(w, appendBigSleb128(b, x))
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.writeValue(w, reflect.ValueOf(entry.Value)); err != nil {
+			return err
+		}
+	}
+	return e.writeByte(w, dictEnd)
+}
+
+// leadIntKey checks that only d's first integer key can read back as a tag
+// when written without its type byte, moving such a key to the front when
+// reorder is set. See [Encoder.writeDict].
+func leadIntKey(d Dict, fixed, reorder bool) error {
+	lead := -1
+	for i, entry := range d {
+		var b []byte
+		if fixed {
+			rv, _ := fixedKey(entry.Key)
+			b = appendDictIntKey(nil, rv, false)
+		} else {
+			x, _ := keyInt(entry.Key)
+			b = appendBigSleb128(nil, x)
+		}
+		if !ambiguousIntKey(b, !fixed) {
+			continue
+		}
+		if lead >= 0 || i > 0 && !reorder {
+			return fmt.Errorf("dict key %v cannot be encoded unambiguously", entry.Key)
+		}
+		lead = i
+	}
+	if lead > 0 {
+		entry := d[lead]
+		copy(d[1:lead+1], d[:lead])
+		d[0] = entry
+	}
+	return nil
+}
+
+// readOrderedDict reads the rest of a dict as a [Dict]. A repeated key keeps
+// its first position and takes the last value.
+func (d *Decoder) readOrderedDict() (Dict, error) {
+	out := Dict{}
+	seen := make(map[interface{}]int)
+	keyTok, err := d.r.Next()
+	if err != nil {
+		return nil, err
+	}
+	intKeyType := d.r.lastIntKeyType
+	for keyTok.A != TokenDictEnd {
+		var key, seenKey interface{}
+		switch keyTok.A {
+		case TokenString:
+			key, seenKey = keyTok.Data, keyTok.Data
+		case TokenInt:
+			key = d.intValue(keyTok)
+			x, _ := bigIntValue(keyTok.Data)
+			seenKey = x.String()
+		default:
+			return nil, errUnexpectedToken(keyTok.A)
+		}
+
+		valTok, err := d.r.Next()
+		if err != nil {
+			return nil, err
+		}
+		val, err := d.tokenToValue(valTok)
+		if err != nil {
+			return nil, err
+		}
+		if i, dup := seen[seenKey]; dup {
+			if d.RejectDuplicateKeys {
+				return nil, errDuplicateKey(key)
+			}
+			out[i].Value = val
+		} else {
+			seen[seenKey] = len(out)
+			out = append(out, DictEntry{Key: key, Value: val})
+		}
+
+		if keyTok.A == TokenInt {
+			keyTok, err = d.r.NextIntKey(intKeyType)
+		} else {
+			keyTok, err = d.r.Next()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func dictDecoder(d *Decoder, tok Token, v reflect.Value) error {
+	if tok.A != TokenDictStart {
+		return errTypeMismatch(tok.A, v.Interface())
+	}
+	dict, err := d.readOrderedDict()
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(dict))
+	return nil
+}
@@ -0,0 +1,147 @@
+package muon
+
+import (
+	"io"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDict_Helpers(t *testing.T) {
+	d := Dict{{"b", 1}, {"a", 2}}
+	d.Set("c", 3)
+	d.Set("b", 4)
+	assert.Equal(t, Dict{{"b", 4}, {"a", 2}, {"c", 3}}, d)
+	assert.Equal(t, []interface{}{"b", "a", "c"}, d.Keys())
+
+	v, ok := d.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	_, ok = d.Get(1)
+	assert.False(t, ok)
+
+	assert.True(t, d.Delete("b"))
+	assert.False(t, d.Delete("b"))
+	assert.Equal(t, Dict{{"a", 2}, {"c", 3}}, d)
+
+	ints := Dict{{int64(1), "x"}, {uint8(7), "y"}, {big.NewInt(-3), "z"}}
+	assert.Equal(t, 0, ints.Index(1))
+	assert.Equal(t, 1, ints.Index(int32(7)))
+	assert.Equal(t, 2, ints.Index(int64(-3)))
+	assert.Equal(t, -1, ints.Index("1"))
+}
+
+func TestDict_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Dict
+	}{
+		{"string keys", Dict{{"zeta", 1}, {"alpha", Dict{{"y", true}, {"x", nil}}}, {"mid", "m"}}},
+		{"sleb keys", Dict{{9, "a"}, {-1, "b"}, {300, "c"}}},
+		{"empty", Dict{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeWith(t, &Encoder{Deterministic: true}, tt.in)
+
+			d := NewDecoder(data)
+			d.OrderedDicts = true
+			v, err := d.Decode()
+			require.NoError(t, err)
+			assert.Equal(t, tt.in, v)
+
+			var out Dict
+			d = NewDecoder(data)
+			d.OrderedDicts = true
+			require.NoError(t, d.Unmarshal(&out))
+			assert.Equal(t, tt.in, out)
+
+			// re-encoding keeps the bytes
+			assert.Equal(t, data, encode(t, out))
+		})
+	}
+}
+
+func TestDict_IntKeyForms(t *testing.T) {
+	// keys of one fixed-size type keep it
+	data := encode(t, Dict{{int16(5), "a"}, {int16(-2), "b"}})
+	assert.Equal(t, []byte{dictStart, typeInt16, 5, 0, 'a', 0, 0xFE, 0xFF, 'b', 0, dictEnd}, data)
+
+	d := NewDecoder(data)
+	d.IntFormat = IntFormatNumber
+	var out Dict
+	require.NoError(t, d.Unmarshal(&out))
+	assert.Equal(t, byte(typeInt16), out[0].Key.(Number).Type)
+	assert.Equal(t, data, encode(t, out))
+
+	// mixed types fall back to SLEB128
+	data = encode(t, Dict{{int16(5), "a"}, {uint64(1 << 40), "b"}})
+	assert.Equal(t, byte(0xBB), data[1])
+	out = nil
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, Dict{{5, "a"}, {1 << 40, "b"}}, out)
+
+	require.Error(t, (&Encoder{}).Write(io.Discard, Dict{{"a", 1}, {2, 2}}))
+}
+
+func TestDict_TagLikeIntKeys(t *testing.T) {
+	// 127 is ff 00 in SLEB128, read as padding; 147 and int8 -109 start with
+	// 0x93, read as the dict end. Only the first key, after the type byte, is
+	// safe.
+	for _, keys := range [][2]interface{}{{0, 127}, {0, 147}, {int8(0), int8(-109)}} {
+		require.Error(t, (&Encoder{}).Write(io.Discard, Dict{{keys[0], "a"}, {keys[1], "b"}}), "%v", keys[1])
+
+		data := encode(t, Dict{{keys[1], "b"}, {keys[0], "a"}})
+		var out Dict
+		require.NoError(t, Unmarshal(data, &out))
+		require.Len(t, out, 2)
+		assert.True(t, sameKey(keys[1], out[0].Key) && sameKey(keys[0], out[1].Key), "%v", keys[1])
+		assert.Equal(t, []interface{}{"b", "a"}, []interface{}{out[0].Value, out[1].Value})
+	}
+
+	// a map has no order to keep, so the key is written first
+	for _, in := range []interface{}{
+		map[int]string{0: "a", 1: "b", 127: "c"},
+		map[int]string{0: "a", 1: "b", 147: "c"},
+		map[int8]string{0: "a", 1: "b", -109: "c"},
+		map[interface{}]interface{}{0: "a", 1: "b", 147: "c"},
+	} {
+		for _, enc := range []*Encoder{{}, {Deterministic: true}} {
+			data := encodeWith(t, enc, in)
+			out := reflect.New(reflect.TypeOf(in))
+			require.NoError(t, Unmarshal(data, out.Interface()))
+			assert.Equal(t, in, out.Elem().Interface())
+		}
+	}
+	for _, in := range []interface{}{
+		map[int]string{127: "a", 147: "b"},
+		map[interface{}]interface{}{127: "a", 147: "b"},
+	} {
+		assert.Error(t, (&Encoder{}).Write(io.Discard, in))
+	}
+}
+
+func TestDict_Duplicates(t *testing.T) {
+	data := []byte{dictStart, 'a', 0, 0xA1, 'b', 0, 0xA2, 'a', 0, 0xA3, dictEnd}
+	var out Dict
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, Dict{{"a", 3}, {"b", 2}}, out)
+
+	d := NewDecoder(data)
+	d.RejectDuplicateKeys = true
+	requireMuonError(t, d.Unmarshal(&out), ErrCodeDuplicateKey)
+}
+
+func TestDict_InStruct(t *testing.T) {
+	type Config struct {
+		Name string `muon:"name"`
+		Env  Dict   `muon:"env"`
+	}
+	in := Config{Name: "svc", Env: Dict{{"PATH", "/bin"}, {"HOME", "/root"}}}
+	var out Config
+	require.NoError(t, Unmarshal(encode(t, in), &out))
+	assert.Equal(t, in, out)
+}
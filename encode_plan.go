@@ -0,0 +1,448 @@
+package muon
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"ekyu.moe/leb128"
+)
+
+// encoderFunc writes v, a value of the type the function was compiled for.
+// Encoder options (LRU, Deterministic, TimeFormat, …) are read from e at call
+// time, so one compiled function serves every Encoder.
+type encoderFunc func(e *Encoder, w io.Writer, v reflect.Value) error
+
+// planKey identifies a compiled encoder or decoder. Struct field names, and
+// so the fields that survive name conflicts, depend on the naming strategy.
+type planKey struct {
+	t      reflect.Type
+	naming Naming
+}
+
+// encoderCache maps planKey → encoderFunc. Entries are built once, on first
+// use of a type, and shared by all encoders.
+var encoderCache sync.Map
+
+// typeEncoder returns the compiled encoder for t under naming strategy n.
+func typeEncoder(t reflect.Type, n Naming) encoderFunc {
+	key := planKey{t, n}
+	if fi, ok := encoderCache.Load(key); ok {
+		return fi.(encoderFunc)
+	}
+
+	// Store a forwarding func first so that recursive types (type T struct
+	// { Next *T }) resolve to it while the real encoder is being built.
+	var (
+		wg sync.WaitGroup
+		f  encoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := encoderCache.LoadOrStore(key, encoderFunc(func(e *Encoder, w io.Writer, v reflect.Value) error {
+		wg.Wait()
+		return f(e, w, v)
+	}))
+	if loaded {
+		return fi.(encoderFunc)
+	}
+
+	f = newTypeEncoder(t, n)
+	wg.Done()
+	encoderCache.Store(key, f)
+	return f
+}
+
+func newTypeEncoder(t reflect.Type, n Naming) encoderFunc {
+	if t == timeType || t == durationType {
+		return func(e *Encoder, w io.Writer, v reflect.Value) error {
+			return e.writeTime(w, v, e.TimeFormat)
+		}
+	}
+
+	if t == numberType {
+		return numberEncoder
+	}
+	if t == float16Type {
+		return float16Encoder
+	}
+	if t == dictType {
+		return dictEncoder
+	}
+	if isBigType(t) {
+		return bigEncoder
+	}
+	if t.Kind() == reflect.Ptr && (isBigType(t.Elem()) || isTimeType(t.Elem())) {
+		// ahead of the marshaler check: math/big types and time.Time are
+		// TextMarshalers
+		return newPtrEncoder(t, n)
+	}
+
+	if t.Kind() != reflect.Interface {
+		if isMarshaler(t) {
+			return marshalerEncoder
+		}
+		if t.Kind() != reflect.Ptr && isMarshaler(reflect.PtrTo(t)) {
+			return addrMarshalerEncoder
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return boolEncoder
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intEncoder
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintEncoder
+	case reflect.Float32, reflect.Float64:
+		return floatEncoder
+	case reflect.String:
+		return stringEncoder
+	case reflect.Interface:
+		return interfaceEncoder
+	case reflect.Struct:
+		return newStructEncoder(t, n)
+	case reflect.Map:
+		return newMapEncoder(t, n)
+	case reflect.Slice, reflect.Array:
+		return newListEncoder(t, n)
+	case reflect.Ptr:
+		return newPtrEncoder(t, n)
+	}
+	return func(e *Encoder, w io.Writer, v reflect.Value) error {
+		return fmt.Errorf("type %s not supportable", t)
+	}
+}
+
+func boolEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	return e.writeBool(w, v.Bool())
+}
+
+func intEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	return e.writeInt64(w, v.Int())
+}
+
+func uintEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	return e.writeUint64(w, v.Uint())
+}
+
+func floatEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	return e.writeFloat(w, v.Float())
+}
+
+func stringEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	return e.writeString(w, v.String())
+}
+
+func interfaceEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	if v.IsNil() {
+		return e.writeByte(w, nilValue)
+	}
+	return e.writeValue(w, v.Elem())
+}
+
+func marshalerEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return e.writeByte(w, nilValue)
+	}
+	return e.writeMarshaler(w, v.Interface())
+}
+
+// addrMarshalerEncoder handles types whose marshaler methods have a pointer
+// receiver. Like encoding/json it takes the address of v; values that are not
+// addressable (map values, fields of a struct passed by copy) are copied
+// first so the method is still honoured.
+func addrMarshalerEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	if v.CanAddr() {
+		return e.writeMarshaler(w, v.Addr().Interface())
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return e.writeMarshaler(w, p.Interface())
+}
+
+func newPtrEncoder(t reflect.Type, n Naming) encoderFunc {
+	elemEnc := typeEncoder(t.Elem(), n)
+	return func(e *Encoder, w io.Writer, v reflect.Value) error {
+		if v.IsNil() {
+			return e.writeByte(w, nilValue)
+		}
+		return elemEnc(e, w, v.Elem())
+	}
+}
+
+func newListEncoder(t reflect.Type, n Naming) encoderFunc {
+	elemType := t.Elem()
+	if elemType == float16Type {
+		return func(e *Encoder, w io.Writer, v reflect.Value) error {
+			return e.writeTypedArray(w, v, typeFloat16)
+		}
+	}
+	// time.Duration is an int64 but follows TimeFormat, element by element
+	if tb, ok := elemKindToTypeByte[elemType.Kind()]; ok && elemType != durationType && !isMarshaler(elemType) && !isMarshaler(reflect.PtrTo(elemType)) {
+		return func(e *Encoder, w io.Writer, v reflect.Value) error {
+			return e.writeTypedArray(w, v, tb)
+		}
+	}
+	elemEnc := typeEncoder(elemType, n)
+	var columnar encoderFunc
+	if t.Kind() == reflect.Slice {
+		columnar = newColumnarEncoder(elemType, n)
+	}
+	k := elemType.Kind()
+	platformInts := (k == reflect.Int || k == reflect.Uint) && !isMarshaler(elemType) && !isMarshaler(reflect.PtrTo(elemType))
+	return func(e *Encoder, w io.Writer, v reflect.Value) error {
+		if platformInts && e.IntSlices != IntSliceList {
+			return e.writeIntTypedArray(w, v)
+		}
+		if k == reflect.Interface && e.DetectTypedArrays && !e.DisableTypedArrays {
+			if elems, tb, ok := detectTypedArray(v); ok {
+				return e.writeTypedArray(w, elems, tb)
+			}
+		}
+		if e.Columnar && columnar != nil {
+			return columnar(e, w, v)
+		}
+		if err := e.writeByte(w, listStart); err != nil {
+			return err
+		}
+		for i, n := 0, v.Len(); i < n; i++ {
+			if err := elemEnc(e, w, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return e.writeByte(w, listEnd)
+	}
+}
+
+func newMapEncoder(t reflect.Type, n Naming) encoderFunc {
+	elemEnc := typeEncoder(t.Elem(), n)
+	if kt := t.Key(); kt.Kind() != reflect.String && kt.Implements(textMarshalerType) {
+		return func(e *Encoder, w io.Writer, v reflect.Value) error {
+			return e.writeTextKeyMap(w, v, elemEnc)
+		}
+	}
+	return func(e *Encoder, w io.Writer, v reflect.Value) error {
+		return e.writeMap(w, v, elemEnc)
+	}
+}
+
+// encodeField is a struct field in a compiled struct encoder.
+type encodeField struct {
+	name  string
+	key   int64 // used instead of name in integer-keyed structs
+	index []int
+	omit  func(reflect.Value) bool // nil when the field is always written
+	enc   encoderFunc
+}
+
+func newStructEncoder(t reflect.Type, n Naming) encoderFunc {
+	fields := structFields(t, n)
+	if structToArray(t) {
+		return newPositionalEncoder(t, fields, n)
+	}
+	keyed, err := structKeys(t, fields)
+	if err != nil {
+		return func(e *Encoder, w io.Writer, v reflect.Value) error {
+			return err
+		}
+	}
+	plan := make([]encodeField, len(fields))
+	for i, f := range fields {
+		ft := t.FieldByIndex(f.index).Type
+		plan[i] = encodeField{
+			name:  f.name,
+			key:   f.info.Key,
+			index: f.index,
+			enc:   newFieldEncoder(ft, f, n),
+		}
+		switch {
+		case f.info.OmitEmpty && f.info.OmitZero:
+			isZero := newZeroChecker(ft)
+			plan[i].omit = func(v reflect.Value) bool { return isEmptyValue(v) || isZero(v) }
+		case f.info.OmitEmpty:
+			plan[i].omit = isEmptyValue
+		case f.info.OmitZero:
+			plan[i].omit = newZeroChecker(ft)
+		}
+	}
+
+	return func(e *Encoder, w io.Writer, v reflect.Value) error {
+		if err := e.writeByte(w, dictStart); err != nil {
+			return err
+		}
+		first := true
+		for i := range plan {
+			f := &plan[i]
+			var fv reflect.Value
+			if len(f.index) == 1 {
+				fv = v.Field(f.index[0])
+			} else {
+				var ok bool
+				if fv, ok = fieldByIndex(v, f.index); !ok {
+					continue
+				}
+			}
+			if f.omit != nil && f.omit(fv) {
+				continue
+			}
+			if keyed {
+				// SLEB128 keys; only the first carries the 0xBB type byte
+				b := e.scratch[:0]
+				if first {
+					b = append(b, 0xBB)
+				}
+				if err := e.flushScratch(w, leb128.AppendSleb128(b, f.key)); err != nil {
+					return err
+				}
+			} else if err := e.writeString(w, f.name); err != nil {
+				return err
+			}
+			first = false
+			if err := f.enc(e, w, fv); err != nil {
+				return err
+			}
+		}
+		return e.writeByte(w, dictEnd)
+	}
+}
+
+// newPositionalEncoder writes struct t as a list of its field values in
+// order. Omit options are ignored, and fields behind a nil embedded pointer
+// are written as nil, so that every value keeps its position.
+func newPositionalEncoder(t reflect.Type, fields []field, n Naming) encoderFunc {
+	plan := make([]encodeField, len(fields))
+	for i, f := range fields {
+		plan[i] = encodeField{
+			index: f.index,
+			enc:   newFieldEncoder(t.FieldByIndex(f.index).Type, f, n),
+		}
+	}
+	return func(e *Encoder, w io.Writer, v reflect.Value) error {
+		if err := e.writeByte(w, listStart); err != nil {
+			return err
+		}
+		for i := range plan {
+			f := &plan[i]
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok {
+				if err := e.writeByte(w, nilValue); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := f.enc(e, w, fv); err != nil {
+				return err
+			}
+		}
+		return e.writeByte(w, listEnd)
+	}
+}
+
+// newFieldEncoder returns the encoder for a struct field of type t, applying
+// its wire type, columnar and time format tag options. A time format also
+// applies to the elements of slices and arrays of time values.
+func newFieldEncoder(t reflect.Type, f field, n Naming) encoderFunc {
+	if f.info.Type != "" {
+		return newWireTypeEncoder(t, f.info.Type)
+	}
+	if f.info.Columnar && t.Kind() == reflect.Slice {
+		if enc := newColumnarEncoder(t.Elem(), n); enc != nil {
+			return enc
+		}
+	}
+	format, ok := timeTagFormats[f.info.Time]
+	if !ok {
+		return typeEncoder(t, n)
+	}
+	base := t
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	switch {
+	case base == timeType || base == durationType:
+		return wrapPtrEncoder(t, func(e *Encoder, w io.Writer, v reflect.Value) error {
+			return e.writeTime(w, v, format)
+		})
+	case (base.Kind() == reflect.Slice || base.Kind() == reflect.Array) && isTimeType(base.Elem()):
+		return wrapPtrEncoder(t, func(e *Encoder, w io.Writer, v reflect.Value) error {
+			if err := e.writeByte(w, listStart); err != nil {
+				return err
+			}
+			for i, n := 0, v.Len(); i < n; i++ {
+				if err := e.writeTime(w, v.Index(i), format); err != nil {
+					return err
+				}
+			}
+			return e.writeByte(w, listEnd)
+		})
+	}
+	return typeEncoder(t, n)
+}
+
+// wrapPtrEncoder extends enc, an encoder for the type t points to through
+// any number of pointers, to t itself. Nil pointers are written as nil.
+func wrapPtrEncoder(t reflect.Type, enc encoderFunc) encoderFunc {
+	for ; t.Kind() == reflect.Ptr; t = t.Elem() {
+		elemEnc := enc
+		enc = func(e *Encoder, w io.Writer, v reflect.Value) error {
+			if v.IsNil() {
+				return e.writeByte(w, nilValue)
+			}
+			return elemEnc(e, w, v.Elem())
+		}
+	}
+	return enc
+}
+
+// isEmptyValue reports whether rv is empty in the sense of the omitempty
+// tag option.
+func isEmptyValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return rv.IsNil()
+	}
+	return false
+}
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// newZeroChecker returns the omitzero test for values of type t: an
+// IsZero() bool method wins over the reflect zero check, and nil pointers
+// and interfaces are always zero.
+func newZeroChecker(t reflect.Type) func(reflect.Value) bool {
+	nilable := t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface
+	switch {
+	case t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if nilable && v.IsNil() {
+				return true
+			}
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case !nilable && reflect.PtrTo(t).Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if !v.CanAddr() {
+				p := reflect.New(t)
+				p.Elem().Set(v)
+				v = p.Elem()
+			}
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
+	}
+	return reflect.Value.IsZero
+}
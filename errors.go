@@ -1,6 +1,10 @@
 package muon
 
-import "fmt"
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
 
 // Error codes returned in [MuonError.Code].
 const (
@@ -10,6 +14,22 @@ const (
 	ErrCodeTypeMismatch
 	// ErrCodeUnexpectedToken is returned when an unexpected token is encountered during decoding.
 	ErrCodeUnexpectedToken
+	// ErrCodeUnknownField is returned under [Decoder.DisallowUnknownFields]
+	// when a dict key matches no field of the target struct.
+	ErrCodeUnknownField
+	// ErrCodeArrayLength is returned under [Decoder.StrictArrayLength] when a
+	// list or TypedArray does not have exactly as many elements as the target
+	// array.
+	ErrCodeArrayLength
+	// ErrCodeTrailingData is returned under [Decoder.RejectTrailingData] when
+	// bytes other than padding follow the decoded value.
+	ErrCodeTrailingData
+	// ErrCodeDuplicateKey is returned under [Decoder.RejectDuplicateKeys] when
+	// a dict contains the same key twice.
+	ErrCodeDuplicateKey
+	// ErrCodeOverflow is returned when an integer element of a TypedArray
+	// does not fit the element type of the target.
+	ErrCodeOverflow
 )
 
 // MuonError is a structured error returned by Unmarshal and other typed decode
@@ -37,3 +57,40 @@ func errTypeMismatch(token TokenEnum, target interface{}) error {
 func errUnexpectedToken(token TokenEnum) error {
 	return MuonError{Code: ErrCodeUnexpectedToken, Msg: fmt.Sprintf("unexpected token: %s", token)}
 }
+
+func errUnknownField(key interface{}, t reflect.Type) error {
+	return MuonError{Code: ErrCodeUnknownField, Msg: fmt.Sprintf("unknown field %s in %s", quoteKey(key), t)}
+}
+
+func errArrayTooLong(t reflect.Type) error {
+	return MuonError{Code: ErrCodeArrayLength, Msg: fmt.Sprintf("index %d out of range for %s", t.Len(), t)}
+}
+
+func errArrayTooShort(t reflect.Type, n int) error {
+	return MuonError{Code: ErrCodeArrayLength, Msg: fmt.Sprintf("%s needs %d elements, got %d", t, t.Len(), n)}
+}
+
+func errTrailingData(offset, n int) error {
+	return MuonError{Code: ErrCodeTrailingData, Msg: fmt.Sprintf("%d bytes of trailing data at offset %d", n, offset)}
+}
+
+func errDuplicateKey(key interface{}) error {
+	return MuonError{Code: ErrCodeDuplicateKey, Msg: fmt.Sprintf("duplicate key %s", quoteKey(key))}
+}
+
+func errOverflow(v interface{}, t reflect.Type) error {
+	return MuonError{Code: ErrCodeOverflow, Msg: fmt.Sprintf("value %v overflows %s", v, t)}
+}
+
+func errKeyOverflow(key interface{}) error {
+	return MuonError{Code: ErrCodeTypeMismatch, Msg: fmt.Sprintf("dict key %v overflows int64", key)}
+}
+
+// quoteKey formats a dict key for an error message: strings quoted,
+// integers as is.
+func quoteKey(key interface{}) string {
+	if s, ok := key.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(key)
+}
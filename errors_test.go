@@ -27,6 +27,11 @@ func TestMuonError_Codes(t *testing.T) {
 	assert.Equal(t, 1, ErrCodeInvalidTarget)
 	assert.Equal(t, 2, ErrCodeTypeMismatch)
 	assert.Equal(t, 3, ErrCodeUnexpectedToken)
+	assert.Equal(t, 4, ErrCodeUnknownField)
+	assert.Equal(t, 5, ErrCodeArrayLength)
+	assert.Equal(t, 6, ErrCodeTrailingData)
+	assert.Equal(t, 7, ErrCodeDuplicateKey)
+	assert.Equal(t, 8, ErrCodeOverflow)
 }
 
 func TestMuonError_IsError(t *testing.T) {
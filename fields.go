@@ -0,0 +1,220 @@
+package muon
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"ekyu.moe/leb128"
+	"github.com/oherych/muon/internal"
+)
+
+// field is a struct field as seen by the encoder and decoder, after the
+// fields of embedded structs have been promoted into their parent.
+type field struct {
+	name  string
+	index []int // path of field indexes from the outer struct
+	info  internal.TagInfo
+}
+
+// structFields returns the fields encoded for struct type t, in declaration
+// order, with untagged fields named by n. It follows the rules of
+// encoding/json:
+//
+//   - exported fields of anonymous struct (or pointer-to-struct) fields are
+//     promoted into the parent, recursively;
+//   - an anonymous struct field with a name in its muon tag is kept as a
+//     nested dict instead;
+//   - when several fields share a name, the shallowest one wins; among equally
+//     deep fields a tagged one wins, and otherwise all of them are dropped.
+func structFields(t reflect.Type, n Naming) []field {
+	type candidate struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []field
+	next := []candidate{{typ: t}}
+	count := map[reflect.Type]int{}
+	nextCount := map[reflect.Type]int{}
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+		current := next
+		next = nil
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, c := range current {
+			if visited[c.typ] {
+				continue
+			}
+			visited[c.typ] = true
+
+			for i := 0; i < c.typ.NumField(); i++ {
+				sf := c.typ.Field(i)
+				ft := sf.Type
+				if sf.Anonymous {
+					if ft.Kind() == reflect.Ptr {
+						if !sf.IsExported() {
+							// cannot be allocated on decode
+							continue
+						}
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+
+				info := internal.ParseTags(sf)
+				if info.Skip {
+					continue
+				}
+				index := make([]int, len(c.index)+1)
+				copy(index, c.index)
+				index[len(c.index)] = i
+
+				if info.Named || !sf.Anonymous || ft.Kind() != reflect.Struct {
+					name := info.Name
+					if !info.Named {
+						name = n.fieldName(sf.Name)
+					}
+					fields = append(fields, field{name: name, index: index, info: info})
+					if count[c.typ] > 1 {
+						// the same struct is embedded twice at this depth:
+						// add a duplicate so the name annihilates below
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, candidate{typ: ft, index: index})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		if len(a.index) != len(b.index) {
+			return len(a.index) < len(b.index)
+		}
+		return a.info.Named && !b.info.Named
+	})
+
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		if f, ok := dominantField(fields[i:j]); ok {
+			out = append(out, f)
+		}
+		i = j
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].index, out[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return out
+}
+
+// structToArray reports whether struct t opts into positional encoding with
+// a blank field tagged toarray:
+//
+//	type Point struct {
+//	    _    struct{} `muon:",toarray"`
+//	    X, Y float64
+//	}
+func structToArray(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); sf.Name == "_" && internal.ParseTags(sf).ToArray {
+			return true
+		}
+	}
+	return false
+}
+
+// structKeys reports whether the fields of struct t are encoded as an
+// integer-keyed dict. That is the case when every field has a key=N option;
+// mixing keyed and named fields, repeating a key or a malformed key option is
+// an error.
+func structKeys(t reflect.Type, fields []field) (bool, error) {
+	keyed := 0
+	seen := map[int64]string{}
+	for _, f := range fields {
+		if f.info.Err != nil {
+			return false, fmt.Errorf("struct %s: field %s: %w", t, f.name, f.info.Err)
+		}
+		if !f.info.Keyed {
+			continue
+		}
+		keyed++
+		if prev, dup := seen[f.info.Key]; dup {
+			return false, fmt.Errorf("struct %s: fields %s and %s share key %d", t, prev, f.name, f.info.Key)
+		}
+		seen[f.info.Key] = f.name
+		// keys after the first are written without a type byte, so their
+		// first byte must not read as the end of the dict or as padding
+		if b := leb128.AppendSleb128(nil, f.info.Key)[0]; b == dictEnd || b == tagPadding {
+			return false, fmt.Errorf("struct %s: key %d of field %s cannot be encoded unambiguously", t, f.info.Key, f.name)
+		}
+	}
+	if keyed > 0 && keyed < len(fields) {
+		return false, fmt.Errorf("struct %s mixes integer-keyed and named fields", t)
+	}
+	return keyed > 0, nil
+}
+
+// dominantField picks the field that wins among fields sharing a name, which
+// are sorted by depth and then tagged-first.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].info.Named == fields[1].info.Named {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+// fieldByIndex returns the field of struct v at index. It reports false when
+// the path goes through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// fieldByIndexAlloc is like fieldByIndex but allocates nil embedded pointers
+// on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
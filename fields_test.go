@@ -0,0 +1,304 @@
+package muon
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Base struct {
+	ID        int       `muon:"id"`
+	CreatedAt time.Time `muon:"created_at"`
+}
+
+type audit struct {
+	By string `muon:"by"`
+}
+
+func fieldNames(t *testing.T, v interface{}) []string {
+	t.Helper()
+	var names []string
+	toks := tokens(t, encode(t, v))
+	depth := 0
+	for i := 0; i < len(toks); i++ {
+		switch toks[i].A {
+		case TokenDictStart, TokenListStart:
+			depth++
+			continue
+		case TokenDictEnd, TokenListEnd:
+			depth--
+			continue
+		}
+		if depth == 1 {
+			names = append(names, toks[i].Data.(string))
+			i++ // skip the scalar value
+		}
+	}
+	return names
+}
+
+func TestEmbedded_Promoted(t *testing.T) {
+	type User struct {
+		Base
+		*audit
+		Name string `muon:"name"`
+	}
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	in := User{Base: Base{ID: 7, CreatedAt: ts}, Name: "alice"}
+
+	// nil embedded pointer: its fields are left out; unexported embedded
+	// pointers are never followed
+	assert.Equal(t, []string{"id", "created_at", "name"}, fieldNames(t, in))
+
+	var out User
+	require.NoError(t, Unmarshal(encode(t, in), &out))
+	assert.Equal(t, 7, out.ID)
+	assert.True(t, ts.Equal(out.CreatedAt))
+	assert.Equal(t, "alice", out.Name)
+}
+
+func TestEmbedded_PointerAllocatedOnDecode(t *testing.T) {
+	type User struct {
+		*Base
+		Name string `muon:"name"`
+	}
+	in := User{Base: &Base{ID: 3}, Name: "bob"}
+	assert.Equal(t, []string{"id", "created_at", "name"}, fieldNames(t, in))
+
+	var out User
+	require.NoError(t, Unmarshal(encode(t, in), &out))
+	require.NotNil(t, out.Base)
+	assert.Equal(t, 3, out.ID)
+}
+
+func TestEmbedded_UnexportedStructPromoted(t *testing.T) {
+	type Doc struct {
+		audit
+		Title string `muon:"title"`
+	}
+	assert.Equal(t, []string{"by", "title"}, fieldNames(t, Doc{audit: audit{By: "x"}}))
+
+	var out Doc
+	require.NoError(t, Unmarshal(encode(t, Doc{audit: audit{By: "x"}}), &out))
+	assert.Equal(t, "x", out.By)
+}
+
+func TestEmbedded_TaggedIsNested(t *testing.T) {
+	type User struct {
+		Base `muon:"base"`
+		Name string `muon:"name"`
+	}
+	in := User{Base: Base{ID: 1}}
+	toks := tokens(t, encode(t, in))
+	assert.Equal(t, Token{A: TokenString, Data: "base"}, toks[1])
+	assert.Equal(t, TokenDictStart, toks[2].A)
+
+	var out User
+	require.NoError(t, Unmarshal(encode(t, in), &out))
+	assert.Equal(t, 1, out.ID)
+}
+
+func TestEmbedded_Conflicts(t *testing.T) {
+	type A struct {
+		X int `muon:"x"`
+		Y int
+	}
+	type B struct {
+		X int `muon:"x"`
+		Y int `muon:"y"`
+	}
+	type C struct {
+		Y int
+	}
+	type S struct {
+		A
+		B
+		Z int `muon:"x"`
+	}
+	// shallower "x" wins over both embedded; tagged B.Y wins over untagged A.Y
+	assert.Equal(t, []string{"y", "x"}, fieldNames(t, S{}))
+
+	type T struct {
+		A
+		C
+	}
+	// untagged A.Y and C.Y at the same depth annihilate each other
+	assert.Equal(t, []string{"x"}, fieldNames(t, T{}))
+}
+
+func TestEmbedded_NonStruct(t *testing.T) {
+	type Label string
+	type S struct {
+		Label
+	}
+	assert.Equal(t, []string{"label"}, fieldNames(t, S{Label: "l"}))
+}
+
+type keyedPoint struct {
+	X     int    `muon:",key=1"`
+	Y     int    `muon:"y,key=2"`
+	Label string `muon:",key=200,omitempty"`
+}
+
+func TestKeyedStruct(t *testing.T) {
+	in := keyedPoint{X: 3, Y: -1, Label: "p"}
+	data := encode(t, in)
+	assert.Equal(t, []byte{
+		dictStart,
+		0xBB, 0x01, 0xA3,
+		0x02, 0xBB, 0x7F,
+		0xC8, 0x01, 'p', 0x00,
+		dictEnd,
+	}, data)
+
+	var out keyedPoint
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+
+	var generic interface{}
+	require.NoError(t, Unmarshal(data, &generic))
+	assert.Equal(t, map[interface{}]interface{}{1: 3, 2: -1, 200: "p"}, generic)
+
+	// the first written key carries the type byte even when earlier fields
+	// are omitted
+	type sparse struct {
+		A int `muon:",key=1,omitempty"`
+		B int `muon:",key=2"`
+	}
+	assert.Equal(t, []byte{dictStart, 0xBB, 0x02, 0xA5, dictEnd}, encode(t, sparse{B: 5}))
+	var s sparse
+	require.NoError(t, Unmarshal(encode(t, sparse{B: 5}), &s))
+	assert.Equal(t, sparse{B: 5}, s)
+}
+
+func TestKeyedStruct_Decode(t *testing.T) {
+	// unknown keys are skipped, or rejected under DisallowUnknownFields
+	data := encode(t, map[int]interface{}{1: 4, 9: []int{1}})
+	var out keyedPoint
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, keyedPoint{X: 4}, out)
+
+	d := NewDecoder(data)
+	d.DisallowUnknownFields = true
+	err := d.Unmarshal(&out)
+	me, ok := err.(MuonError)
+	require.True(t, ok)
+	assert.Equal(t, "unknown field 9 in muon.keyedPoint", me.Msg)
+
+	// typed LE keys are accepted too
+	require.NoError(t, Unmarshal(encodeWith(t, &Encoder{Deterministic: true}, map[uint8]int{1: 7, 2: 8}), &out))
+	assert.Equal(t, 7, out.X)
+	assert.Equal(t, 8, out.Y)
+
+	// int keys are not accepted for structs without keyed fields
+	var named struct{ X int }
+	err = Unmarshal(data, &named)
+	me, ok = err.(MuonError)
+	require.True(t, ok)
+	assert.Equal(t, ErrCodeUnexpectedToken, me.Code)
+}
+
+func TestKeyedStruct_Errors(t *testing.T) {
+	var buf bytes.Buffer
+	var enc Encoder
+	err := enc.Write(&buf, struct {
+		A int `muon:",key=1"`
+		B int `muon:"b"`
+	}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mixes integer-keyed and named fields")
+	assert.Error(t, enc.Write(&buf, struct {
+		A int `muon:"a,key=1"`
+		B int `muon:",key=1"`
+	}{}))
+	// 0x93 would read as the end of the dict
+	assert.Error(t, enc.Write(&buf, struct {
+		A int `muon:",key=147"`
+	}{}))
+	// a malformed key option is reported, not ignored
+	err = enc.Write(&buf, struct {
+		A int `muon:",key=abc"`
+	}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid muon tag option "key=abc"`)
+	assert.Error(t, enc.Write(&buf, struct {
+		A int `muon:",key="`
+	}{}))
+	var out struct {
+		A int `muon:",key="`
+	}
+	err = Unmarshal(encode(t, map[string]int{"a": 1}), &out)
+	me, ok := err.(MuonError)
+	require.True(t, ok)
+	assert.Equal(t, ErrCodeInvalidTarget, me.Code)
+
+	// a numeric name is a string key; int keys need key=N
+	assert.Equal(t, encode(t, map[string]int{"1": 2}), encode(t, struct {
+		A int `muon:"1"`
+	}{A: 2}))
+}
+
+type posPoint struct {
+	_     struct{} `muon:",toarray"`
+	X     int      `muon:"x"`
+	Y     int      `muon:"y,omitempty"`
+	Label string   `muon:"label"`
+}
+
+func TestToArray(t *testing.T) {
+	in := posPoint{X: 1, Label: "a"}
+	data := encode(t, in)
+	assert.Equal(t, []byte{listStart, 0xA1, 0xA0, 'a', 0x00, listEnd}, data)
+
+	var out posPoint
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+
+	// the dict form is still accepted
+	require.NoError(t, Unmarshal(encode(t, map[string]interface{}{"x": 2, "label": "b"}), &out))
+	assert.Equal(t, posPoint{X: 2, Label: "b"}, out)
+
+	// nested in slices and other structs
+	type track struct {
+		Points []posPoint `muon:"points"`
+	}
+	var tr track
+	require.NoError(t, Unmarshal(encode(t, track{Points: []posPoint{in, in}}), &tr))
+	assert.Equal(t, []posPoint{in, in}, tr.Points)
+}
+
+func TestToArray_SchemaEvolution(t *testing.T) {
+	// shorter list: trailing fields keep their values
+	out := posPoint{Label: "keep"}
+	require.NoError(t, Unmarshal(encode(t, []interface{}{5, 6}), &out))
+	assert.Equal(t, posPoint{X: 5, Y: 6, Label: "keep"}, out)
+
+	// longer list: extra values, including containers, are skipped
+	extra := []interface{}{1, 2, "c", []interface{}{"x"}, map[string]int{"k": 1}, true}
+	var pair struct {
+		_    struct{} `muon:",toarray"`
+		P    posPoint
+		Next string
+	}
+	require.NoError(t, Unmarshal(encode(t, []interface{}{extra, "next"}), &pair))
+	assert.Equal(t, posPoint{X: 1, Y: 2, Label: "c"}, pair.P)
+	assert.Equal(t, "next", pair.Next)
+}
+
+func TestToArray_NilEmbedded(t *testing.T) {
+	type row struct {
+		_ struct{} `muon:",toarray"`
+		*Base
+		Name string `muon:"name"`
+	}
+	data := encode(t, row{Name: "n"})
+	assert.Equal(t, []byte{listStart, nilValue, nilValue, 'n', 0x00, listEnd}, data)
+
+	var out row
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Nil(t, out.Base)
+	assert.Equal(t, "n", out.Name)
+}
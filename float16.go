@@ -0,0 +1,144 @@
+package muon
+
+import (
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Float16 is an IEEE 754 half-precision (binary16) float, stored as its
+// bits. It is written as a float16 scalar (0xB8), and a []Float16 as a
+// float16 TypedArray; the [Reader] returns float16 TypedArrays as []Float16.
+//
+// Float16 holds about three significant decimal digits and values up to
+// 65504, enough for sensor readings, weights and other data where size
+// matters more than precision.
+type Float16 uint16
+
+var float16Type = reflect.TypeOf(Float16(0))
+
+// NewFloat16 returns f rounded to the nearest Float16, ties to even. Values
+// beyond the Float16 range become infinities.
+func NewFloat16(f float64) Float16 {
+	return Float16(float64ToFloat16(f))
+}
+
+// Float16FromBits returns the Float16 with the given IEEE 754 bits.
+func Float16FromBits(b uint16) Float16 {
+	return Float16(b)
+}
+
+// Bits returns the IEEE 754 bits of h.
+func (h Float16) Bits() uint16 {
+	return uint16(h)
+}
+
+// Float64 returns h as a float64. The conversion is exact.
+func (h Float16) Float64() float64 {
+	return float16ToFloat64(uint16(h))
+}
+
+// Float32 returns h as a float32. The conversion is exact.
+func (h Float16) Float32() float32 {
+	return float32(h.Float64())
+}
+
+// String returns h in the shortest decimal form that reads back to the same
+// Float16.
+func (h Float16) String() string {
+	f := h.Float64()
+	for prec := 1; prec < 5; prec++ {
+		s := strconv.FormatFloat(f, 'g', prec, 64)
+		if g, err := strconv.ParseFloat(s, 64); err == nil && NewFloat16(g) == h {
+			return s
+		}
+	}
+	return strconv.FormatFloat(f, 'g', 5, 64) // always enough for binary16
+}
+
+func float16Encoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	return e.flushScratch(w, appendUint16(append(e.scratch[:0], floatF16), uint16(v.Uint())))
+}
+
+func float16Decoder(d *Decoder, tok Token, v reflect.Value) error {
+	switch tok.A {
+	case TokenFloat:
+		v.SetUint(uint64(float64ToFloat16(tok.Data.(float64))))
+		return nil
+	case TokenInt:
+		n, err := toInt64(tok.Data)
+		if err != nil {
+			return err
+		}
+		v.SetUint(uint64(float64ToFloat16(float64(n))))
+		return nil
+	}
+	return errTypeMismatch(tok.A, v.Interface())
+}
+
+// float16ToFloat64 converts an IEEE 754 half-precision (binary16) value to float64.
+func float16ToFloat64(bits uint16) float64 {
+	sign := uint64(bits>>15) << 63
+	exp := (bits >> 10) & 0x1F
+	mant := uint64(bits & 0x3FF)
+
+	var f64bits uint64
+	switch exp {
+	case 0: // subnormal
+		if mant == 0 {
+			f64bits = sign
+		} else {
+			// normalize
+			exp64 := uint64(1023 - 14)
+			for mant&0x400 == 0 {
+				mant <<= 1
+				exp64--
+			}
+			mant &^= 0x400
+			f64bits = sign | (exp64 << 52) | (mant << 42)
+		}
+	case 0x1F: // inf or nan
+		f64bits = sign | (0x7FF << 52) | (mant << 42)
+	default:
+		f64bits = sign | (uint64(exp+1023-15) << 52) | (mant << 42)
+	}
+	return math.Float64frombits(f64bits)
+}
+
+// float64ToFloat16 converts f to IEEE 754 half precision (binary16),
+// rounding to nearest even. Values too large become infinities and NaNs
+// become the quiet NaN 0x7E00.
+func float64ToFloat16(f float64) uint16 {
+	bits := math.Float64bits(f)
+	sign := uint16(bits>>48) & 0x8000
+	exp := int(bits>>52) & 0x7FF
+	mant := bits & (1<<52 - 1)
+	if exp == 0x7FF {
+		if mant != 0 {
+			return sign | 0x7E00
+		}
+		return sign | 0x7C00
+	}
+
+	e := exp - 1023 + 15
+	var q uint64
+	var shift uint
+	switch {
+	case e >= 0x1F:
+		return sign | 0x7C00
+	case e > 0:
+		q, shift = uint64(e)<<10|mant>>42, 42
+	case e >= -10: // subnormal
+		mant |= 1 << 52
+		shift = uint(43 - e)
+		q = mant >> shift
+	default:
+		return sign
+	}
+	rem, half := mant&(1<<shift-1), uint64(1)<<(shift-1)
+	if rem > half || rem == half && q&1 == 1 {
+		q++ // may carry into the exponent, up to infinity
+	}
+	return sign | uint16(q)
+}
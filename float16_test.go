@@ -0,0 +1,103 @@
+package muon
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFloat16(t *testing.T) {
+	h := NewFloat16(0.1)
+	assert.Equal(t, uint16(0x2E66), h.Bits())
+	assert.Equal(t, "0.1", h.String())
+	assert.Equal(t, float32(0.0999755859375), h.Float32())
+	assert.Equal(t, 0.0999755859375, h.Float64())
+	assert.Equal(t, h, Float16FromBits(0x2E66))
+	assert.Equal(t, "6.55e+04", NewFloat16(65504).String()) // 65500 rounds to 65504
+	assert.Equal(t, "+Inf", NewFloat16(1e6).String())
+	assert.Equal(t, "NaN", NewFloat16(math.NaN()).String())
+}
+
+func TestFloat16_Encode(t *testing.T) {
+	data := encode(t, NewFloat16(1.5))
+	assert.Equal(t, []byte{floatF16, 0x00, 0x3E}, data)
+	var out Float16
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, NewFloat16(1.5), out)
+
+	// wider floats and integers decode into a Float16
+	require.NoError(t, Unmarshal(encode(t, 0.25), &out))
+	assert.Equal(t, NewFloat16(0.25), out)
+	require.NoError(t, Unmarshal(encode(t, -3), &out))
+	assert.Equal(t, NewFloat16(-3), out)
+	assert.Error(t, Unmarshal(encode(t, "x"), &out))
+}
+
+func TestFloat16_TypedArray(t *testing.T) {
+	in := []Float16{NewFloat16(0.5), NewFloat16(-2), NewFloat16(math.Inf(1))}
+	data := encode(t, in)
+	assert.Equal(t, []byte{typedArray, typeFloat16, 3, 0x00, 0x38, 0x00, 0xC0, 0x00, 0x7C}, data)
+	assert.Equal(t, []Token{{A: TokenTypedArray, Data: in}}, tokens(t, data))
+
+	var out []Float16
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+
+	var wide []float64
+	require.NoError(t, Unmarshal(data, &wide))
+	assert.Equal(t, []float64{0.5, -2, math.Inf(1)}, wide)
+
+	// float TypedArrays narrow into []Float16, integer ones do not
+	require.NoError(t, Unmarshal(encode(t, []float32{0.5, -2}), &out))
+	assert.Equal(t, in[:2], out)
+	assert.Error(t, Unmarshal(encode(t, []int8{1}), &out))
+
+	t.Run("list", func(t *testing.T) {
+		data := encodeWith(t, &Encoder{DisableTypedArrays: true}, in[:1])
+		assert.Equal(t, []byte{listStart, floatF16, 0x00, 0x38, listEnd}, data)
+		var out []Float16
+		require.NoError(t, Unmarshal(data, &out))
+		assert.Equal(t, in[:1], out)
+	})
+}
+
+func TestFloat16_Chunked(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, (&Encoder{}).WriteChunkedTypedArray(&buf, TypeByteFloat16,
+		[]Float16{NewFloat16(1)}, []float32{0.5, 2}))
+	assert.Equal(t, []Token{
+		{A: TokenTypedArray, Data: []Float16{NewFloat16(1), NewFloat16(0.5), NewFloat16(2)}},
+	}, tokens(t, buf.Bytes()))
+}
+
+func TestFloat64ToFloat16(t *testing.T) {
+	for _, tc := range []struct {
+		in   float64
+		want uint16
+	}{
+		{0, 0x0000},
+		{math.Copysign(0, -1), 0x8000},
+		{1, 0x3C00},
+		{-2, 0xC000},
+		{65504, 0x7BFF},
+		{65520, 0x7C00}, // rounds up to +Inf
+		{1e10, 0x7C00},
+		{math.Inf(-1), 0xFC00},
+		{math.NaN(), 0x7E00},
+		{math.Ldexp(1, -14), 0x0400},     // smallest normal
+		{math.Ldexp(1, -24), 0x0001},     // smallest subnormal
+		{math.Ldexp(1, -25), 0x0000},     // tie, rounds to even
+		{math.Ldexp(3, -26), 0x0001},     // above the tie
+		{1 + math.Ldexp(1, -11), 0x3C00}, // tie, rounds to even
+		{1 + math.Ldexp(3, -11), 0x3C02}, // tie, rounds to even
+		{1 + math.Ldexp(1, -10), 0x3C01},
+	} {
+		assert.Equal(t, tc.want, float64ToFloat16(tc.in), "%v", tc.in)
+		if !math.IsNaN(tc.in) && tc.want&0x7C00 != 0x7C00 {
+			assert.Equal(t, float64ToFloat16(float16ToFloat64(tc.want)), tc.want)
+		}
+	}
+}
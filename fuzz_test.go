@@ -14,6 +14,34 @@ func FuzzString(f *testing.F) {
 	f.Fuzz(func(t *testing.T, in string) { fuzzRoundTrip(t, in) })
 }
 
+// FuzzByteString checks that arbitrary bytes, including invalid UTF-8 and
+// bytes that look like tags, survive as strings and do not disturb the
+// values around them, with and without the LRU.
+func FuzzByteString(f *testing.F) {
+	f.Add([]byte("test"))
+	f.Add([]byte{})
+	f.Add([]byte{listStart, listEnd})
+	f.Add([]byte{tagPadding, 0x00, 0x61})
+	f.Add([]byte{typeInt8, 0x01})
+	f.Add([]byte{0xE2, 0x82})
+	f.Fuzz(func(t *testing.T, in []byte) {
+		s := string(in)
+		for _, enc := range []*Encoder{{}, {LRU: true}} {
+			var buf bytes.Buffer
+			if err := enc.Write(&buf, []interface{}{s, s, 7}); err != nil {
+				t.Fatal("encode:", err)
+			}
+			var out []interface{}
+			if err := Unmarshal(buf.Bytes(), &out); err != nil {
+				t.Fatal("unmarshal:", err)
+			}
+			if len(out) != 3 || out[0] != s || out[1] != s || out[2] != 7 {
+				t.Errorf("round-trip mismatch: in=%q out=%q", s, out)
+			}
+		}
+	})
+}
+
 func FuzzInt64(f *testing.F) {
 	f.Add(int64(0))
 	f.Add(int64(10))
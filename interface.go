@@ -14,3 +14,14 @@ type Marshaler interface {
 type MarshalerStream interface {
 	MarshalMuon(w io.Writer) error
 }
+
+// MarshalerTo is implemented by types that encode themselves through the
+// [Encoder] that is writing them. Nested values written with enc.Write share
+// the encoder's LRU table and Deterministic setting, so the output stays
+// consistent with the rest of the document. It takes precedence over
+// [Marshaler] and [MarshalerStream].
+//
+// MarshalMuonTo must write exactly one complete value to w.
+type MarshalerTo interface {
+	MarshalMuonTo(enc *Encoder, w io.Writer) error
+}
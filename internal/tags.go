@@ -1,7 +1,9 @@
 package internal
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -12,20 +14,85 @@ const (
 type TagInfo struct {
 	Name string
 	Skip bool
+	// Named is true when Name was given in the tag rather than derived from
+	// the Go field name.
+	Named bool
+	// Time is the time.Time / time.Duration encoding requested by one of the
+	// rfc3339, unixnano or unixms options; empty when none is given.
+	Time string
+	// OmitEmpty skips the field when it is false, 0, "", a nil pointer or
+	// interface, or an empty slice, map or array.
+	OmitEmpty bool
+	// OmitZero skips the field when it holds its zero value or its
+	// IsZero() bool method reports true.
+	OmitZero bool
+	// Aliases are extra names accepted for the field when decoding, given as
+	// alias=name options.
+	Aliases []string
+	// Key is the integer dict key of the field, given as a key=N option; it
+	// is only meaningful when Keyed is true. A numeric name is a string key
+	// like any other.
+	Key   int64
+	Keyed bool
+	// ToArray, set on a blank (_) field, encodes the enclosing struct as a
+	// list of its field values in order.
+	ToArray bool
+	// Columnar encodes a slice of flat structs as a dict of columns.
+	Columnar bool
+	// Type is the wire type requested with a type=NAME option (i8…u64,
+	// f16, f32, f64 or leb); empty when none is given.
+	Type string
+	// Err reports a malformed option, such as a key= option that is not an
+	// integer.
+	Err error
 }
 
 func ParseTags(field reflect.StructField) TagInfo {
 	val := field.Tag.Get(tag)
 	parts := strings.Split(val, ",")
 
-	skip := parts[0] == "-"
-
-	if parts[0] == "" {
-		parts[0] = strings.ToLower(field.Name)
+	info := TagInfo{
+		Name:  parts[0],
+		Skip:  parts[0] == "-",
+		Named: parts[0] != "",
+	}
+	if info.Name == "" {
+		info.Name = strings.ToLower(field.Name)
 	}
 
-	return TagInfo{
-		Name: parts[0],
-		Skip: skip,
+	for _, opt := range parts[1:] {
+		if key := strings.TrimPrefix(opt, "key="); key != opt {
+			n, err := strconv.ParseInt(key, 10, 64)
+			if err != nil {
+				info.Err = fmt.Errorf("invalid muon tag option %q: key must be an integer", opt)
+				continue
+			}
+			info.Key, info.Keyed = n, true
+			continue
+		}
+		if typ := strings.TrimPrefix(opt, "type="); typ != opt {
+			info.Type = typ
+			continue
+		}
+		if alias := strings.TrimPrefix(opt, "alias="); alias != opt {
+			if alias != "" {
+				info.Aliases = append(info.Aliases, alias)
+			}
+			continue
+		}
+		switch opt {
+		case "rfc3339", "unixnano", "unixms":
+			info.Time = opt
+		case "omitempty":
+			info.OmitEmpty = true
+		case "omitzero":
+			info.OmitZero = true
+		case "toarray":
+			info.ToArray = true
+		case "columnar":
+			info.Columnar = true
+		}
 	}
+
+	return info
 }
@@ -3,6 +3,8 @@ package internal
 import (
 	"reflect"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestParseTags(t *testing.T) {
@@ -20,3 +22,38 @@ func TestParseTags(t *testing.T) {
 		t.Log(got)
 	}
 }
+
+func TestParseTags_Options(t *testing.T) {
+	type AA struct {
+		Created int64     `muon:"ts,unixnano"`
+		Updated int64     `muon:",unixms"`
+		Seen    int64     `muon:"seen,rfc3339,unknown"`
+		Note    int64     `muon:"note,omitempty,omitzero"`
+		Renamed int64     `muon:"user_id,alias=userid,alias=uid,alias="`
+		Numeric int64     `muon:"7"`
+		Keyed   int64     `muon:"id,key=-3"`
+		BadKey  int64     `muon:",key=x"`
+		NoKey   int64     `muon:",key="`
+		_       struct{}  `muon:",toarray"`
+		Rows    []int     `muon:"rows,columnar"`
+		Samples []float64 `muon:"samples,type=f32"`
+	}
+
+	typ := reflect.TypeOf(AA{})
+	assert.Equal(t, TagInfo{Name: "ts", Named: true, Time: "unixnano"}, ParseTags(typ.Field(0)))
+	assert.Equal(t, TagInfo{Name: "updated", Time: "unixms"}, ParseTags(typ.Field(1)))
+	assert.Equal(t, TagInfo{Name: "seen", Named: true, Time: "rfc3339"}, ParseTags(typ.Field(2)))
+	assert.Equal(t, TagInfo{Name: "note", Named: true, OmitEmpty: true, OmitZero: true}, ParseTags(typ.Field(3)))
+	assert.Equal(t, TagInfo{Name: "user_id", Named: true, Aliases: []string{"userid", "uid"}}, ParseTags(typ.Field(4)))
+	assert.Equal(t, TagInfo{Name: "7", Named: true}, ParseTags(typ.Field(5)))
+	assert.Equal(t, TagInfo{Name: "id", Named: true, Key: -3, Keyed: true}, ParseTags(typ.Field(6)))
+	bad := ParseTags(typ.Field(7))
+	if assert.Error(t, bad.Err) {
+		assert.Equal(t, `invalid muon tag option "key=x": key must be an integer`, bad.Err.Error())
+	}
+	assert.False(t, bad.Keyed)
+	assert.Error(t, ParseTags(typ.Field(8)).Err)
+	assert.Equal(t, TagInfo{Name: "_", ToArray: true}, ParseTags(typ.Field(9)))
+	assert.Equal(t, TagInfo{Name: "rows", Named: true, Columnar: true}, ParseTags(typ.Field(10)))
+	assert.Equal(t, TagInfo{Name: "samples", Named: true, Type: "f32"}, ParseTags(typ.Field(11)))
+}
@@ -0,0 +1,221 @@
+package muon
+
+import "errors"
+
+// errNoElement is returned when an iterator is read before Next reported an
+// element.
+var errNoElement = errors.New("no current element: call Next first")
+
+// ListIter reads the elements of a list one at a time; see
+// [Decoder.EnterList]. Only the current element is held in memory.
+type ListIter struct {
+	d       *Decoder
+	tok     Token // first token of the current element
+	pending bool  // the current element has not been read yet
+	done    bool
+	err     error
+}
+
+// EnterList reads the start of a list, which must be the next value, and
+// returns an iterator over its elements. Loop with [ListIter.Next] and read
+// each element with [ListIter.Unmarshal] or [ListIter.Decode]; elements that
+// are not read are skipped. Once Next returns false the Decoder is
+// positioned after the list.
+//
+//	it, err := d.EnterList()
+//	for it.Next() {
+//		var rec Record
+//		if err := it.Unmarshal(&rec); err != nil { … }
+//	}
+//	err = it.Err()
+func (d *Decoder) EnterList() (*ListIter, error) {
+	tok, err := d.nextValueToken()
+	if err != nil {
+		return nil, err
+	}
+	if tok.A != TokenListStart {
+		return nil, errUnexpectedToken(tok.A)
+	}
+	return &ListIter{d: d}, nil
+}
+
+// Next advances to the next element and reports whether there is one. It
+// returns false at the end of the list or on an error; see [ListIter.Err].
+func (it *ListIter) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	if it.pending {
+		it.pending = false
+		if it.err = it.d.skipValueFrom(it.tok); it.err != nil {
+			return false
+		}
+	}
+	tok, err := it.d.nextValueToken()
+	if err != nil {
+		it.err = err
+		return false
+	}
+	if tok.A == TokenListEnd {
+		it.done = true
+		return false
+	}
+	it.tok, it.pending = tok, true
+	return true
+}
+
+// Unmarshal stores the current element into target, like
+// [Decoder.Unmarshal]. An error ends the iteration.
+func (it *ListIter) Unmarshal(target interface{}) error {
+	if !it.pending {
+		return errNoElement
+	}
+	v, err := targetValue(target)
+	if err != nil {
+		return err
+	}
+	it.pending = false
+	it.err = it.d.unmarshalToken(it.tok, v)
+	return it.err
+}
+
+// Decode returns the current element as a Go value, like
+// [Decoder.Decode]. An error ends the iteration.
+func (it *ListIter) Decode() (interface{}, error) {
+	if !it.pending {
+		return nil, errNoElement
+	}
+	it.pending = false
+	var v interface{}
+	v, it.err = it.d.tokenToValue(it.tok)
+	return v, it.err
+}
+
+// Err returns the error that ended the iteration, if any.
+func (it *ListIter) Err() error {
+	return it.err
+}
+
+// DictIter reads the entries of a dict one at a time; see
+// [Decoder.EnterDict]. Only the current entry is held in memory, so
+// [Decoder.RejectDuplicateKeys] is not checked.
+type DictIter struct {
+	d          *Decoder
+	key        interface{}
+	tok        Token // first token of the current value
+	pending    bool  // the current value has not been read yet
+	started    bool
+	intKeyType byte // type byte of the first key when keys are integers; 0 for string keys
+	done       bool
+	err        error
+}
+
+// EnterDict reads the start of a dict, which must be the next value, and
+// returns an iterator over its entries. It works like [Decoder.EnterList]:
+// loop with [DictIter.Next], then use [DictIter.Key] and read the value with
+// [DictIter.Unmarshal] or [DictIter.Decode].
+func (d *Decoder) EnterDict() (*DictIter, error) {
+	tok, err := d.nextValueToken()
+	if err != nil {
+		return nil, err
+	}
+	if tok.A != TokenDictStart {
+		return nil, errUnexpectedToken(tok.A)
+	}
+	return &DictIter{d: d}, nil
+}
+
+// Next advances to the next entry and reports whether there is one. It
+// returns false at the end of the dict or on an error; see [DictIter.Err].
+func (it *DictIter) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	if it.pending {
+		it.pending = false
+		if it.err = it.d.skipValueFrom(it.tok); it.err != nil {
+			return false
+		}
+	}
+
+	var keyTok Token
+	if it.intKeyType != 0 {
+		keyTok, it.err = it.d.r.NextIntKey(it.intKeyType)
+	} else {
+		keyTok, it.err = it.d.r.Next()
+	}
+	if it.err != nil {
+		return false
+	}
+	switch {
+	case keyTok.A == TokenDictEnd:
+		it.done = true
+		return false
+	case keyTok.A == TokenString && it.intKeyType == 0:
+		it.key = keyTok.Data
+	case keyTok.A == TokenInt && (it.intKeyType != 0 || !it.started):
+		if !it.started {
+			it.intKeyType = it.d.r.lastIntKeyType
+		}
+		it.key = it.d.intValue(keyTok)
+	default:
+		it.err = errUnexpectedToken(keyTok.A)
+		return false
+	}
+	it.started = true
+
+	if it.tok, it.err = it.d.nextValueToken(); it.err != nil {
+		return false
+	}
+	it.pending = true
+	return true
+}
+
+// Key returns the key of the current entry: a string, or an integer in the
+// Decoder's [IntFormat].
+func (it *DictIter) Key() interface{} {
+	return it.key
+}
+
+// Unmarshal stores the value of the current entry into target, like
+// [Decoder.Unmarshal]. An error ends the iteration.
+func (it *DictIter) Unmarshal(target interface{}) error {
+	if !it.pending {
+		return errNoElement
+	}
+	v, err := targetValue(target)
+	if err != nil {
+		return err
+	}
+	it.pending = false
+	it.err = it.d.unmarshalToken(it.tok, v)
+	return it.err
+}
+
+// Decode returns the value of the current entry as a Go value, like
+// [Decoder.Decode]. An error ends the iteration.
+func (it *DictIter) Decode() (interface{}, error) {
+	if !it.pending {
+		return nil, errNoElement
+	}
+	it.pending = false
+	var v interface{}
+	v, it.err = it.d.tokenToValue(it.tok)
+	return v, it.err
+}
+
+// Err returns the error that ended the iteration, if any.
+func (it *DictIter) Err() error {
+	return it.err
+}
+
+// nextValueToken returns the first token of the next value, skipping magic
+// and count tags.
+func (d *Decoder) nextValueToken() (Token, error) {
+	for {
+		tok, err := d.r.Next()
+		if err != nil || tok.A != TokenMagic && tok.A != TokenCount {
+			return tok, err
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package muon
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type record struct {
+	ID   int    `muon:"id"`
+	Name string `muon:"name"`
+}
+
+func TestDecoder_EnterList(t *testing.T) {
+	var buf bytes.Buffer
+	enc := &Encoder{LRU: true}
+	require.NoError(t, enc.WriteWithMagic(&buf, []interface{}{
+		record{ID: 1, Name: "a"},
+		[]int{9, 9}, // skipped unread
+		record{ID: 2, Name: "b"},
+		"tail",
+	}))
+	require.NoError(t, enc.Write(&buf, "next"))
+
+	d := NewDecoder(buf.Bytes())
+	it, err := d.EnterList()
+	require.NoError(t, err)
+
+	var got []record
+	for i := 0; it.Next(); i++ {
+		switch i {
+		case 0, 2:
+			var rec record
+			require.NoError(t, it.Unmarshal(&rec))
+			got = append(got, rec)
+		case 3:
+			v, err := it.Decode()
+			require.NoError(t, err)
+			assert.Equal(t, "tail", v)
+		}
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []record{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}, got)
+	assert.False(t, it.Next())
+
+	// the decoder continues after the list
+	v, err := d.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, "next", v)
+
+	t.Run("skip int-keyed dict", func(t *testing.T) {
+		// 146 is raw key byte 0x92, which reads as a dict start as a token
+		d := NewDecoder(encode(t, []interface{}{
+			Dict{{Key: uint8(0), Value: 1}, {Key: uint8(146), Value: 2}},
+			map[int]Dict{1: {{Key: int16(5), Value: 3}, {Key: int16(146), Value: 4}}},
+			"after",
+		}))
+		it, err := d.EnterList()
+		require.NoError(t, err)
+		require.True(t, it.Next())
+		require.True(t, it.Next())
+		require.True(t, it.Next())
+		v, err := it.Decode()
+		require.NoError(t, err)
+		assert.Equal(t, "after", v)
+		assert.False(t, it.Next())
+		require.NoError(t, it.Err())
+	})
+
+	t.Run("not a list", func(t *testing.T) {
+		_, err := NewDecoder(encode(t, "x")).EnterList()
+		assert.Error(t, err)
+	})
+
+	t.Run("misuse", func(t *testing.T) {
+		it, err := NewDecoder(encode(t, []int{1})).EnterList()
+		require.NoError(t, err)
+		var n int
+		assert.Error(t, it.Unmarshal(&n), "before Next")
+		require.True(t, it.Next())
+		assert.Error(t, it.Unmarshal(n), "non-pointer target")
+		require.NoError(t, it.Unmarshal(&n))
+		assert.Equal(t, 1, n)
+		assert.False(t, it.Next())
+		assert.NoError(t, it.Err())
+	})
+
+	t.Run("element error", func(t *testing.T) {
+		it, err := NewDecoder(encode(t, []interface{}{"x", 1})).EnterList()
+		require.NoError(t, err)
+		require.True(t, it.Next())
+		var n int
+		assert.Error(t, it.Unmarshal(&n))
+		assert.False(t, it.Next())
+		assert.Error(t, it.Err())
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		data := encode(t, []int{1, 2})
+		it, err := NewDecoder(data[:len(data)-1]).EnterList()
+		require.NoError(t, err)
+		for it.Next() {
+		}
+		assert.Equal(t, io.EOF, it.Err())
+	})
+}
+
+func TestDecoder_EnterDict(t *testing.T) {
+	in := Dict{
+		{Key: "first", Value: record{ID: 1}},
+		{Key: "skip", Value: map[string]int{"x": 1}},
+		{Key: "last", Value: []string{"z"}},
+	}
+	d := NewDecoder(encode(t, in))
+	it, err := d.EnterDict()
+	require.NoError(t, err)
+
+	var keys []interface{}
+	for it.Next() {
+		keys = append(keys, it.Key())
+		switch it.Key() {
+		case "first":
+			var rec record
+			require.NoError(t, it.Unmarshal(&rec))
+			assert.Equal(t, record{ID: 1}, rec)
+		case "last":
+			v, err := it.Decode()
+			require.NoError(t, err)
+			assert.Equal(t, []interface{}{"z"}, v)
+		}
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []interface{}{"first", "skip", "last"}, keys)
+
+	t.Run("int keys", func(t *testing.T) {
+		d := NewDecoder(encode(t, map[int16]string{-2: "a", 300: "b"}))
+		d.IntFormat = IntFormatInt64
+		it, err := d.EnterDict()
+		require.NoError(t, err)
+		got := map[interface{}]string{}
+		for it.Next() {
+			var s string
+			require.NoError(t, it.Unmarshal(&s))
+			got[it.Key()] = s
+		}
+		require.NoError(t, it.Err())
+		assert.Equal(t, map[interface{}]string{int64(-2): "a", int64(300): "b"}, got)
+	})
+
+	t.Run("skip int-keyed dict", func(t *testing.T) {
+		d := NewDecoder(encode(t, Dict{
+			{Key: "skip", Value: Dict{{Key: uint8(0), Value: 1}, {Key: uint8(146), Value: 2}}},
+			{Key: "last", Value: true},
+		}))
+		it, err := d.EnterDict()
+		require.NoError(t, err)
+		require.True(t, it.Next())
+		require.True(t, it.Next())
+		assert.Equal(t, "last", it.Key())
+		assert.False(t, it.Next())
+		require.NoError(t, it.Err())
+	})
+
+	t.Run("not a dict", func(t *testing.T) {
+		_, err := NewDecoder(encode(t, []int{1})).EnterDict()
+		assert.Error(t, err)
+	})
+}
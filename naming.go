@@ -0,0 +1,92 @@
+package muon
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Naming selects how the wire name of a struct field is derived from its Go
+// name when the muon tag does not give one. Tagged names are always used as
+// written.
+//
+//	type User struct {
+//	    UserID    int
+//	    HTTPProxy string
+//	}
+//
+// encodes its fields as "userid"/"httpproxy" under [NamingLower],
+// "user_id"/"http_proxy" under [NamingSnake], "userId"/"httpProxy" under
+// [NamingCamel], "user-id"/"http-proxy" under [NamingKebab] and
+// "UserID"/"HTTPProxy" under [NamingExact].
+type Naming int
+
+const (
+	// NamingLower lower-cases the Go name.
+	NamingLower Naming = iota
+	// NamingExact uses the Go name unchanged.
+	NamingExact
+	// NamingSnake writes lower-case words joined by underscores.
+	NamingSnake
+	// NamingCamel writes the first word in lower case and capitalizes the
+	// rest.
+	NamingCamel
+	// NamingKebab writes lower-case words joined by hyphens.
+	NamingKebab
+)
+
+// fieldName returns the wire name of the Go field name under n.
+func (n Naming) fieldName(name string) string {
+	switch n {
+	case NamingExact:
+		return name
+	case NamingSnake:
+		return strings.ToLower(strings.Join(splitWords(name), "_"))
+	case NamingKebab:
+		return strings.ToLower(strings.Join(splitWords(name), "-"))
+	case NamingCamel:
+		words := splitWords(name)
+		for i, w := range words {
+			w = strings.ToLower(w)
+			if i > 0 {
+				r := []rune(w)
+				r[0] = unicode.ToUpper(r[0])
+				w = string(r)
+			}
+			words[i] = w
+		}
+		return strings.Join(words, "")
+	}
+	return strings.ToLower(name)
+}
+
+// splitWords breaks a Go identifier into words at underscores, at
+// lower-to-upper case changes and before the last capital of an acronym that
+// is followed by a lower-case letter: "HTTPServerID" → HTTP, Server, ID.
+// Digits stay with the word before them.
+func splitWords(name string) []string {
+	var words []string
+	r := []rune(name)
+	start := 0
+	for i := 0; i < len(r); i++ {
+		if r[i] == '_' {
+			if i > start {
+				words = append(words, string(r[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i == start || !unicode.IsUpper(r[i]) {
+			continue
+		}
+		prev := r[i-1]
+		nextLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+			words = append(words, string(r[start:i]))
+			start = i
+		}
+	}
+	if start < len(r) {
+		words = append(words, string(r[start:]))
+	}
+	return words
+}
@@ -0,0 +1,102 @@
+package muon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNaming_FieldName(t *testing.T) {
+	tests := []struct {
+		in                                string
+		lower, exact, snake, camel, kebab string
+	}{
+		{"UserID", "userid", "UserID", "user_id", "userId", "user-id"},
+		{"HTTPServerID", "httpserverid", "HTTPServerID", "http_server_id", "httpServerId", "http-server-id"},
+		{"Name", "name", "Name", "name", "name", "name"},
+		{"URL", "url", "URL", "url", "url", "url"},
+		{"Field2Name", "field2name", "Field2Name", "field2_name", "field2Name", "field2-name"},
+		{"Created_At", "created_at", "Created_At", "created_at", "createdAt", "created-at"},
+		{"ÜberFlag", "überflag", "ÜberFlag", "über_flag", "überFlag", "über-flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.lower, NamingLower.fieldName(tt.in))
+			assert.Equal(t, tt.exact, NamingExact.fieldName(tt.in))
+			assert.Equal(t, tt.snake, NamingSnake.fieldName(tt.in))
+			assert.Equal(t, tt.camel, NamingCamel.fieldName(tt.in))
+			assert.Equal(t, tt.kebab, NamingKebab.fieldName(tt.in))
+		})
+	}
+}
+
+type namedUser struct {
+	UserID    int    `muon:""`
+	FirstName string `muon:",omitempty"`
+	Email     string `muon:"mail"`
+}
+
+func TestNaming_EncoderDecoder(t *testing.T) {
+	in := namedUser{UserID: 1, FirstName: "Ann", Email: "a@b"}
+
+	assert.Equal(t, []string{"userid", "firstname", "mail"}, fieldNames(t, in))
+
+	data := encodeWith(t, &Encoder{Naming: NamingSnake}, in)
+	toks := tokens(t, data)
+	assert.Equal(t, Token{A: TokenString, Data: "user_id"}, toks[1])
+	assert.Equal(t, Token{A: TokenString, Data: "first_name"}, toks[3])
+	assert.Equal(t, Token{A: TokenString, Data: "mail"}, toks[5])
+
+	d := NewDecoder(data)
+	d.Naming = NamingSnake
+	var out namedUser
+	require.NoError(t, d.Unmarshal(&out))
+	assert.Equal(t, in, out)
+
+	// the same type under another strategy uses its own plan
+	var lower namedUser
+	require.NoError(t, Unmarshal(data, &lower))
+	assert.Equal(t, namedUser{Email: "a@b"}, lower)
+}
+
+func TestNaming_Aliases(t *testing.T) {
+	type V2 struct {
+		UserID int    `muon:"user_id,alias=userid,alias=uid"`
+		Name   string `muon:"name"`
+		Nick   string `muon:"nick,alias=name"` // never shadows a field name
+	}
+	for _, key := range []string{"user_id", "userid", "uid"} {
+		var out V2
+		require.NoError(t, Unmarshal(encode(t, map[string]interface{}{key: 5, "name": "n"}), &out))
+		assert.Equal(t, V2{UserID: 5, Name: "n"}, out, key)
+	}
+	// aliases are for decoding only
+	assert.Equal(t, []string{"user_id", "name", "nick"}, fieldNames(t, V2{}))
+}
+
+func TestDecoder_CaseInsensitive(t *testing.T) {
+	type S struct {
+		UserID int    `muon:"user_id,alias=legacyId"`
+		Name   string `muon:"name"`
+		NAME   string `muon:"NAME"`
+	}
+	data := encodeWith(t, &Encoder{Deterministic: true}, map[string]interface{}{"USER_ID": 1, "Name": "x", "NAME": "y"})
+
+	var out S
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, S{NAME: "y"}, out)
+
+	d := NewDecoder(data)
+	d.CaseInsensitive = true
+	out = S{}
+	require.NoError(t, d.Unmarshal(&out))
+	// exact matches win; "Name" folds onto the first declared field
+	assert.Equal(t, S{UserID: 1, Name: "x", NAME: "y"}, out)
+
+	d = NewDecoder(encode(t, map[string]int{"LEGACYID": 2}))
+	d.CaseInsensitive = true
+	out = S{}
+	require.NoError(t, d.Unmarshal(&out))
+	assert.Equal(t, 2, out.UserID)
+}
@@ -0,0 +1,160 @@
+package muon
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+
+	"ekyu.moe/leb128"
+)
+
+// IntFormat selects the Go type of integers that [Decoder.Decode] and
+// interface{} targets of [Decoder.Unmarshal] produce.
+type IntFormat int
+
+const (
+	// IntFormatDefault yields int for values read from inline, 8 to 32-bit
+	// and SLEB128 forms, int64 or uint64 for 64-bit forms, and *big.Int for
+	// SLEB128 values beyond 64 bits.
+	IntFormatDefault IntFormat = iota
+	// IntFormatInt64 yields int64. Only values that do not fit, positive ones
+	// above math.MaxInt64 and SLEB128 values beyond 64 bits, are returned as
+	// uint64 and *big.Int.
+	IntFormatInt64
+	// IntFormatNumber yields [Number], which keeps the wire form.
+	IntFormatNumber
+)
+
+// Number is an integer together with the type byte it was written with.
+// Decoding with [IntFormatNumber], or into a Number target, and encoding the
+// result again reproduces the original bytes.
+//
+// The zero Number is 0.
+type Number struct {
+	// Type is the wire type byte: 0xA0–0xA9 for the inline integers 0–9,
+	// [TypeByteInt8]…[TypeByteUint64] for fixed-size integers and 0xBB for
+	// SLEB128. Zero lets the encoder pick the shortest form.
+	Type byte
+
+	v interface{} // int64, uint64 or *big.Int; nil means 0
+}
+
+var numberType = reflect.TypeOf(Number{})
+
+// newNumber returns the Number read with type byte typ from a TokenInt
+// value.
+func newNumber(typ byte, v interface{}) Number {
+	if n, ok := v.(int); ok {
+		v = int64(n)
+	}
+	return Number{Type: typ, v: v}
+}
+
+// Int64 returns n as an int64, or an error if it does not fit.
+func (n Number) Int64() (int64, error) {
+	if n.v == nil {
+		return 0, nil
+	}
+	return toInt64(n.v)
+}
+
+// Uint64 returns n as a uint64, or an error if it is negative or does not
+// fit.
+func (n Number) Uint64() (uint64, error) {
+	switch v := n.v.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		if v < 0 {
+			return 0, fmt.Errorf("integer %d overflows uint64", v)
+		}
+	}
+	return toUint64(n.v)
+}
+
+// BigInt returns n as a new *big.Int.
+func (n Number) BigInt() *big.Int {
+	if n.v == nil {
+		return new(big.Int)
+	}
+	x, _ := bigIntValue(n.v)
+	return new(big.Int).Set(x)
+}
+
+// String returns n in base 10.
+func (n Number) String() string {
+	switch v := n.v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case *big.Int:
+		return v.String()
+	}
+	return "0"
+}
+
+func numberEncoder(e *Encoder, w io.Writer, v reflect.Value) error {
+	return e.writeNumber(w, v.Interface().(Number))
+}
+
+func (e *Encoder) writeNumber(w io.Writer, n Number) error {
+	switch {
+	case n.Type >= 0xA0 && n.Type <= 0xA9:
+		return e.writeByte(w, n.Type)
+	case n.Type >= typeInt8 && n.Type <= typeUint64:
+		sizes := [8]int{1, 2, 4, 8, 1, 2, 4, 8} // B0..B7
+		var bits uint64
+		switch v := n.v.(type) {
+		case int64:
+			bits = uint64(v)
+		case uint64:
+			bits = v
+		}
+		b := append(e.scratch[:0], n.Type)
+		return e.flushScratch(w, appendUint64(b, bits)[:1+sizes[n.Type-typeInt8]])
+	}
+	switch v := n.v.(type) {
+	case int64:
+		if n.Type == 0xBB {
+			return e.flushScratch(w, leb128.AppendSleb128(append(e.scratch[:0], 0xBB), v))
+		}
+		return e.writeInt64(w, v)
+	case uint64:
+		if v <= math.MaxInt64 && n.Type == 0xBB {
+			return e.flushScratch(w, leb128.AppendSleb128(append(e.scratch[:0], 0xBB), int64(v)))
+		}
+		return e.writeUint64(w, v)
+	case *big.Int:
+		return e.writeBigInt(w, v)
+	}
+	return e.writeInt64(w, 0)
+}
+
+// intValue returns the value of an integer token in the Decoder's
+// [IntFormat].
+func (d *Decoder) intValue(tok Token) interface{} {
+	switch d.IntFormat {
+	case IntFormatInt64:
+		if n, ok := tok.Data.(int); ok {
+			return int64(n)
+		}
+		if n, ok := tok.Data.(uint64); ok && n <= math.MaxInt64 {
+			return int64(n)
+		}
+	case IntFormatNumber:
+		return newNumber(d.r.lastType, tok.Data)
+	}
+	return tok.Data
+}
+
+func numberDecoder(d *Decoder, tok Token, v reflect.Value) error {
+	if tok.A != TokenInt {
+		return errTypeMismatch(tok.A, v.Interface())
+	}
+	v.Set(reflect.ValueOf(newNumber(d.r.lastType, tok.Data)))
+	return nil
+}
@@ -0,0 +1,138 @@
+package muon
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeWith(t *testing.T, d *Decoder) interface{} {
+	t.Helper()
+	v, err := d.Decode()
+	require.NoError(t, err)
+	return v
+}
+
+func TestDecoder_IntFormat(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		name        string
+		data        []byte
+		def, int64s interface{}
+		numberType  byte
+		numberStr   string
+	}{
+		{"inline", []byte{0xA7}, 7, int64(7), 0xA7, "7"},
+		{"int8", []byte{typeInt8, 0xFE}, -2, int64(-2), typeInt8, "-2"},
+		{"uint32", []byte{typeUint32, 1, 0, 0, 0}, 1, int64(1), typeUint32, "1"},
+		{"int64", []byte{typeInt64, 1, 0, 0, 0, 0, 0, 0, 0}, int64(1), int64(1), typeInt64, "1"},
+		{"uint64", []byte{typeUint64, 1, 0, 0, 0, 0, 0, 0, 0}, uint64(1), int64(1), typeUint64, "1"},
+		{"uint64 max", encode(t, uint64(math.MaxUint64)), uint64(math.MaxUint64), uint64(math.MaxUint64), 0xBB, "18446744073709551615"},
+		{"sleb", []byte{0xBB, 0x7F}, -1, int64(-1), 0xBB, "-1"},
+		{"sleb small", []byte{0xBB, 0x03}, 3, int64(3), 0xBB, "3"},
+		{"big", encode(t, huge), huge, huge, 0xBB, huge.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.def, decodeWith(t, NewDecoder(tt.data)))
+
+			d := NewDecoder(tt.data)
+			d.IntFormat = IntFormatInt64
+			assert.Equal(t, tt.int64s, decodeWith(t, d))
+
+			d = NewDecoder(tt.data)
+			d.IntFormat = IntFormatNumber
+			n, ok := decodeWith(t, d).(Number)
+			require.True(t, ok)
+			assert.Equal(t, tt.numberType, n.Type)
+			assert.Equal(t, tt.numberStr, n.String())
+			assert.Equal(t, tt.numberStr, n.BigInt().String())
+			// re-encoding keeps the wire form
+			assert.Equal(t, tt.data, encode(t, n))
+		})
+	}
+}
+
+func TestNumber(t *testing.T) {
+	var zero Number
+	assert.Equal(t, []byte{0xA0}, encode(t, zero))
+	assert.Equal(t, "0", zero.String())
+
+	type S struct {
+		ID   Number `muon:"id"`
+		Rest []interface{}
+	}
+	data := encode(t, map[string]interface{}{
+		"id":   int16(300),
+		"rest": []interface{}{uint8(4), int64(-5)},
+	})
+	var out S
+	d := NewDecoder(data)
+	d.IntFormat = IntFormatNumber
+	require.NoError(t, d.Unmarshal(&out))
+	assert.Equal(t, byte(0xBB), out.ID.Type)
+	i, err := out.ID.Int64()
+	require.NoError(t, err)
+	assert.Equal(t, int64(300), i)
+
+	neg := out.Rest[1].(Number)
+	_, err = neg.Uint64()
+	require.Error(t, err)
+	u, err := out.Rest[0].(Number).Uint64()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), u)
+
+	// the list re-encodes to the same bytes
+	var buf bytes.Buffer
+	require.NoError(t, (&Encoder{}).Write(&buf, out.Rest))
+	assert.Equal(t, encode(t, []interface{}{uint8(4), int64(-5)}), buf.Bytes())
+
+	require.Error(t, Unmarshal(encode(t, "1"), &out.ID))
+}
+
+func TestDecoder_Float32(t *testing.T) {
+	f32 := []byte{floatF32, 0, 0, 0xC0, 0x3F} // 1.5
+	f16 := []byte{floatF16, 0x00, 0x3E}       // 1.5
+	assert.Equal(t, 1.5, decodeWith(t, NewDecoder(f32)))
+
+	for _, data := range [][]byte{f32, f16} {
+		d := NewDecoder(data)
+		d.Float32 = true
+		assert.Equal(t, float32(1.5), decodeWith(t, d))
+	}
+	d := NewDecoder(encode(t, 1.5))
+	d.Float32 = true
+	assert.Equal(t, 1.5, decodeWith(t, d))
+}
+
+func TestDecoder_Int64Keys(t *testing.T) {
+	data := encode(t, map[int32]interface{}{1: "a", -2: map[uint8]int{3: 4}})
+	d := NewDecoder(data)
+	d.Int64Keys = true
+	assert.Equal(t, map[int64]interface{}{
+		1:  "a",
+		-2: map[int64]interface{}{3: 4},
+	}, decodeWith(t, d))
+
+	d = NewDecoder(encode(t, map[uint64]int{math.MaxUint64: 1}))
+	d.Int64Keys = true
+	_, err := d.Decode()
+	requireMuonError(t, err, ErrCodeTypeMismatch)
+	assert.Equal(t, "dict key 18446744073709551615 overflows int64", err.(MuonError).Msg)
+
+	d = NewDecoder([]byte{dictStart, 0xBB, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7E, 0xA1, dictEnd})
+	d.Int64Keys = true
+	_, err = d.Decode()
+	requireMuonError(t, err, ErrCodeTypeMismatch)
+	assert.Equal(t, "dict key -18446744073709551616 overflows int64", err.(MuonError).Msg)
+
+	// keys follow IntFormat in map[interface{}]interface{}
+	d = NewDecoder(data)
+	d.IntFormat = IntFormatInt64
+	v := decodeWith(t, d).(map[interface{}]interface{})
+	assert.Equal(t, "a", v[int64(1)])
+}
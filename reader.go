@@ -18,6 +18,7 @@ type Reader struct {
 	scanp          int
 	lru            []string
 	lastIntKeyType byte // type byte of the most recently decoded typed int key (0xB0..0xB7 or 0xBB)
+	lastType       byte // type byte of the most recent token, telling number forms apart
 }
 
 // Token is a single decoded muon value returned by [Reader.Next].
@@ -37,10 +38,7 @@ func NewByteReader(in []byte) Reader {
 // Returns io.EOF when all bytes have been consumed.
 // Padding bytes (0xFF) are silently skipped before each token.
 func (r *Reader) Next() (Token, error) {
-	// skip padding bytes
-	for r.scanp < len(r.in) && r.in[r.scanp] == tagPadding {
-		r.scanp++
-	}
+	r.skipPadding()
 
 	if r.scanp >= len(r.in) {
 		return Token{}, io.EOF
@@ -48,6 +46,7 @@ func (r *Reader) Next() (Token, error) {
 
 	first := r.in[r.scanp]
 	r.scanp++
+	r.lastType = first
 
 	// magic signature: 0x8F 0xB5 0x30 0x31
 	if first == tagMagicByte {
@@ -60,7 +59,7 @@ func (r *Reader) Next() (Token, error) {
 
 	// count tag: 0x8A + ULEB128
 	if first == tagCount {
-		n, size := leb128.DecodeUleb128(r.in[r.scanp:])
+		n, size := leb128.DecodeUleb128(r.lebBytes())
 		r.scanp += int(size)
 		return Token{A: TokenCount, Data: n}, nil
 	}
@@ -126,13 +125,11 @@ func (r *Reader) Next() (Token, error) {
 	// signed LEB128 integer
 	if first == 0xBB {
 		r.lastIntKeyType = 0xBB
-		v, n := leb128.DecodeSleb128(r.in[r.scanp:])
-		r.scanp += int(n)
-		return Token{A: TokenInt, Data: int(v)}, nil
+		return r.readSleb()
 	}
 
 	// float16
-	if first == 0xB8 {
+	if first == floatF16 {
 		if r.scanp+2 > len(r.in) {
 			return Token{}, io.EOF
 		}
@@ -152,7 +149,7 @@ func (r *Reader) Next() (Token, error) {
 	}
 
 	// float32
-	if first == 0xB9 {
+	if first == floatF32 {
 		if r.scanp+4 > len(r.in) {
 			return Token{}, io.EOF
 		}
@@ -177,7 +174,7 @@ func (r *Reader) Next() (Token, error) {
 
 	// string reference: 0x81 + ULEB128(index) → LRU lookup
 	if first == stringRef {
-		idx, n := leb128.DecodeUleb128(r.in[r.scanp:])
+		idx, n := leb128.DecodeUleb128(r.lebBytes())
 		r.scanp += int(n)
 		if int(idx) >= len(r.lru) {
 			return Token{}, fmt.Errorf("string ref index %d out of range (lru size %d)", idx, len(r.lru))
@@ -206,7 +203,7 @@ func (r *Reader) Next() (Token, error) {
 		}
 		typeByte := r.in[r.scanp]
 		r.scanp++
-		count, n := leb128.DecodeUleb128(r.in[r.scanp:])
+		count, n := leb128.DecodeUleb128(r.lebBytes())
 		r.scanp += int(n)
 		data, err := r.readTypedElems(typeByte, int(count))
 		if err != nil {
@@ -217,7 +214,7 @@ func (r *Reader) Next() (Token, error) {
 
 	// size-tagged (fixed-length) string: 0x8B + ULEB128(len) + bytes
 	if first == tagSize {
-		length, n := leb128.DecodeUleb128(r.in[r.scanp:])
+		length, n := leb128.DecodeUleb128(r.lebBytes())
 		r.scanp += int(n)
 		end := r.scanp + int(length)
 		if end > len(r.in) {
@@ -335,6 +332,16 @@ func (r *Reader) readTypedElems(typeByte byte, count int) (interface{}, error) {
 			out[i] = binary.LittleEndian.Uint64(b[i*8:])
 		}
 		return out, nil
+	case typeFloat16:
+		b, err := read(2)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]Float16, count)
+		for i := range out {
+			out[i] = Float16(binary.LittleEndian.Uint16(b[i*2:]))
+		}
+		return out, nil
 	case typeFloat32:
 		b, err := read(4)
 		if err != nil {
@@ -363,7 +370,7 @@ func (r *Reader) readChunkedTypedElems(typeByte byte) (interface{}, error) {
 	// read chunks until zero-length terminator, aggregate into one slice
 	var allElems []interface{}
 	for {
-		count, n := leb128.DecodeUleb128(r.in[r.scanp:])
+		count, n := leb128.DecodeUleb128(r.lebBytes())
 		r.scanp += int(n)
 		if count == 0 {
 			break
@@ -430,6 +437,12 @@ func mergeTypedSlices(chunks []interface{}) interface{} {
 			out = append(out, c.([]uint64)...)
 		}
 		return out
+	case []Float16:
+		var out []Float16
+		for _, c := range chunks {
+			out = append(out, c.([]Float16)...)
+		}
+		return out
 	case []float32:
 		var out []float32
 		for _, c := range chunks {
@@ -450,10 +463,11 @@ func mergeTypedSlices(chunks []interface{}) interface{} {
 // typeByte (0xB0–0xB7 or 0xBB). Per the muon spec, only the first key in an
 // integer-keyed dict carries a type prefix; all subsequent keys are raw bytes
 // of the same size. Returns TokenDictEnd if the closing 0x93 byte is next.
+// Padding is skipped before SLEB128 keys only: a fixed-size key may start
+// with 0xFF, as math.MaxUint64 does.
 func (r *Reader) NextIntKey(typeByte byte) (Token, error) {
-	// skip padding
-	for r.scanp < len(r.in) && r.in[r.scanp] == tagPadding {
-		r.scanp++
+	if typeByte == 0xBB {
+		r.skipPadding()
 	}
 	if r.scanp >= len(r.in) {
 		return Token{}, io.EOF
@@ -463,11 +477,10 @@ func (r *Reader) NextIntKey(typeByte byte) (Token, error) {
 		r.scanp++
 		return Token{A: TokenDictEnd}, nil
 	}
+	r.lastType = typeByte
 	// check for SLEB128 (0xBB) int key
 	if typeByte == 0xBB {
-		v, n := leb128.DecodeSleb128(r.in[r.scanp:])
-		r.scanp += int(n)
-		return Token{A: TokenInt, Data: int(v)}, nil
+		return r.readSleb()
 	}
 	// typed LE integer
 	sizes := [8]int{1, 2, 4, 8, 1, 2, 4, 8} // B0..B7
@@ -509,38 +522,63 @@ func (r *Reader) NextIntKey(typeByte byte) (Token, error) {
 	return Token{}, io.EOF
 }
 
+// skipPadding advances past any padding bytes (0xFF).
+func (r *Reader) skipPadding() {
+	for r.scanp < len(r.in) && r.in[r.scanp] == tagPadding {
+		r.scanp++
+	}
+}
+
+// readSleb reads an SLEB128 integer. Values that fit in an int64 are
+// returned as int, larger positive ones that fit in a uint64 as uint64, and
+// anything longer as *big.Int.
+func (r *Reader) readSleb() (Token, error) {
+	size := 0
+	for r.scanp+size < len(r.in) && r.in[r.scanp+size]&0x80 != 0 {
+		size++
+	}
+	if r.scanp+size >= len(r.in) {
+		return Token{}, io.EOF
+	}
+	size++
+	if size < maxLebSize {
+		v, _ := leb128.DecodeSleb128(r.in[r.scanp : r.scanp+size])
+		r.scanp += size
+		return Token{A: TokenInt, Data: int(v)}, nil
+	}
+	x, _ := decodeBigSleb128(r.in[r.scanp : r.scanp+size])
+	r.scanp += size
+	switch {
+	case x.IsInt64():
+		return Token{A: TokenInt, Data: int(x.Int64())}, nil
+	case x.IsUint64():
+		return Token{A: TokenInt, Data: x.Uint64()}, nil
+	}
+	return Token{A: TokenInt, Data: x}, nil
+}
+
+// maxLebSize is the longest LEB128 encoding of a 64-bit value.
+const maxLebSize = 10
+
+// lebBytes returns the unread input cut to at most maxLebSize bytes. The
+// leb128 decoders only look at len(b)&0xff bytes, so passing the whole tail
+// would decode a zero whenever its length is a multiple of 256.
+func (r *Reader) lebBytes() []byte {
+	return r.lebBytesAt(r.scanp)
+}
+
+// lebBytesAt is lebBytes for the input from offset p.
+func (r *Reader) lebBytesAt(p int) []byte {
+	end := p + maxLebSize
+	if end > len(r.in) {
+		end = len(r.in)
+	}
+	return r.in[p:end]
+}
+
 func (r *Reader) lruPrepend(s string) {
 	if len(r.lru) >= lruMaxSize {
 		r.lru = r.lru[:lruMaxSize-1]
 	}
 	r.lru = append([]string{s}, r.lru...)
 }
-
-// float16ToFloat64 converts an IEEE 754 half-precision (binary16) value to float64.
-func float16ToFloat64(bits uint16) float64 {
-	sign := uint64(bits>>15) << 63
-	exp := (bits >> 10) & 0x1F
-	mant := uint64(bits & 0x3FF)
-
-	var f64bits uint64
-	switch exp {
-	case 0: // subnormal
-		if mant == 0 {
-			f64bits = sign
-		} else {
-			// normalize
-			exp64 := uint64(1023 - 14)
-			for mant&0x400 == 0 {
-				mant <<= 1
-				exp64--
-			}
-			mant &^= 0x400
-			f64bits = sign | (exp64 << 52) | (mant << 42)
-		}
-	case 0x1F: // inf or nan
-		f64bits = sign | (0x7FF << 52) | (mant << 42)
-	default:
-		f64bits = sign | (uint64(exp+1023-15) << 52) | (mant << 42)
-	}
-	return math.Float64frombits(f64bits)
-}
@@ -1,10 +1,12 @@
 package muon
 
 import (
+	"bytes"
 	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewReader(t *testing.T) {
@@ -29,3 +31,56 @@ func TestNewReader(t *testing.T) {
 	}
 
 }
+
+func TestReader_LebBeforeMultipleOf256(t *testing.T) {
+	// the leb128 count must decode correctly whatever the number of bytes
+	// left after it
+	for _, tail := range []int{0, 1, 253, 509} {
+		data := append([]byte{typedArray, typeUint8, 0x02, 7, 8}, make([]byte, tail)...)
+		r := NewByteReader(data)
+		tok, err := r.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, Token{A: TokenTypedArray, Data: []uint8{7, 8}}, tok, "tail %d", tail)
+	}
+}
+
+func TestReader_LebLengthRegression(t *testing.T) {
+	// leb128.DecodeUleb128 only looks at len(b)&0xff bytes, so every LEB128
+	// read must be cut to the LEB itself: with the whole unread input passed
+	// in, a value followed by a multiple of 256 bytes decoded as zero.
+	cases := map[string]struct {
+		data []byte
+		want []Token
+	}{
+		"sleb":        {[]byte{0xBB, 0xE4, 0x00}, []Token{{A: TokenInt, Data: 100}}},
+		"count":       {[]byte{tagCount, 0x05, boolTrue}, []Token{{A: TokenCount, Data: uint64(5)}, {A: TokenTrue}}},
+		"string ref":  {[]byte{tagRefString, 'a', stringEnd, tagRefString, 'b', stringEnd, stringRef, 0x01}, []Token{{A: TokenString, Data: "a"}, {A: TokenString, Data: "b"}, {A: TokenString, Data: "a"}}},
+		"size":        {[]byte{tagSize, 0x02, 'h', 'i'}, []Token{{A: TokenString, Data: "hi"}}},
+		"typed array": {[]byte{typedArray, typeUint8, 0x01, 7}, []Token{{A: TokenTypedArray, Data: []uint8{7}}}},
+		"chunked":     {[]byte{typedArrayChunk, typeUint8, 0x01, 7, 0x01, 8, 0x00}, []Token{{A: TokenTypedArray, Data: []uint8{7, 8}}}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// every remainder modulo 256 after each LEB, twice over
+			for tail := 0; tail < 512; tail++ {
+				r := NewByteReader(append(append([]byte{}, tc.data...), make([]byte, tail)...))
+				for _, want := range tc.want {
+					tok, err := r.Next()
+					require.NoError(t, err, "tail %d", tail)
+					require.Equal(t, want, tok, "tail %d", tail)
+				}
+			}
+		})
+	}
+
+	t.Run("blob", func(t *testing.T) {
+		for tail := 0; tail < 512; tail++ {
+			data := append([]byte{typedArrayChunk, typeUint8, 0x01, 7, 0x00}, make([]byte, tail)...)
+			var buf bytes.Buffer
+			r := NewByteReader(data)
+			n, err := r.ReadBlobTo(&buf)
+			require.NoError(t, err, "tail %d", tail)
+			require.Equal(t, int64(1), n, "tail %d", tail)
+		}
+	})
+}
@@ -6,6 +6,7 @@ package muon
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"math"
 	"testing"
@@ -48,26 +49,175 @@ func tokens(t *testing.T, data []byte) []Token {
 	return out
 }
 
+// specFixtures holds the document of every spec test below that reads or
+// writes a single value, by test name. TestWrite_DecodeRoundTrip decodes
+// and re-encodes each of them, so a new single-document test adds its
+// document here.
+var specFixtures = map[string]func(t *testing.T) []byte{
+	"String_NullTerminated": func(t *testing.T) []byte {
+		return encode(t, "hello")
+	},
+	"String_Empty": func(t *testing.T) []byte {
+		return encode(t, "")
+	},
+	"String_ContainsNull": func(t *testing.T) []byte {
+		return encode(t, "te\x00st")
+	},
+	"String_Long": func(t *testing.T) []byte {
+		return encode(t, string(make([]byte, 512)))
+	},
+	"String_LRU_FirstOccurrence": func(t *testing.T) []byte {
+		return encodeWith(t, &Encoder{LRU: true}, "foo")
+	},
+	"TypedInt_Int8": func(t *testing.T) []byte {
+		return encode(t, []int8{-4, -3, -2, -1, 0, 1, 2, 3, 4})
+	},
+	"TypedInt_Uint8": func(t *testing.T) []byte {
+		return encode(t, []uint8{0, 1, 2, 3, 4})
+	},
+	"TypedInt_Int32": func(t *testing.T) []byte {
+		return encode(t, []int32{-4, -3, -2, -1, 0, 1, 2, 3, 4})
+	},
+	"TypedInt_Int64": func(t *testing.T) []byte {
+		return encode(t, []int64{-4, -3, -2, -1, 0, 1, 2, 3, 4})
+	},
+	"TypedFloat_Float32": func(t *testing.T) []byte {
+		return encode(t, []float32{1.2, 3.4, 5.6})
+	},
+	"TypedFloat_Float64": func(t *testing.T) []byte {
+		return encode(t, []float64{1.2, 3.4, 5.6})
+	},
+	"Float_F64_Pi": func(t *testing.T) []byte {
+		return encode(t, math.Pi)
+	},
+	"Float_NaN": func(t *testing.T) []byte {
+		return encode(t, math.NaN())
+	},
+	"Float_NegInf": func(t *testing.T) []byte {
+		return encode(t, math.Inf(-1))
+	},
+	"Float_PosInf": func(t *testing.T) []byte {
+		return encode(t, math.Inf(1))
+	},
+	"Float_F32_StandaloneWrite": func(t *testing.T) []byte {
+		return encode(t, float32(1.5))
+	},
+	"TypedArray_Empty": func(t *testing.T) []byte {
+		return encode(t, []int32{})
+	},
+	"List_Empty": func(t *testing.T) []byte {
+		return encode(t, []interface{}{})
+	},
+	"List_Mixed": func(t *testing.T) []byte {
+		return encode(t, []interface{}{"a", 1, true, nil})
+	},
+	"List_Nested": func(t *testing.T) []byte {
+		return encode(t, []interface{}{[]interface{}{}, []interface{}{}})
+	},
+	"Dict_Empty": func(t *testing.T) []byte {
+		return encode(t, map[string]int{})
+	},
+	"Dict_StringKeys": func(t *testing.T) []byte {
+		return encode(t, map[string]interface{}{"key": "val"})
+	},
+	"Decoder_NestedList": func(t *testing.T) []byte {
+		return encode(t, []interface{}{[]interface{}{"x"}, []interface{}{"y"}})
+	},
+	"Decoder_TypedArray": func(t *testing.T) []byte {
+		return encode(t, []float64{1.1, 2.2, 3.3})
+	},
+	"Float_F16_Read": func(t *testing.T) []byte {
+		return []byte{0xB8, 0x00, 0x3C}
+	},
+	"Dict_IntKeys_Int8": func(t *testing.T) []byte {
+		return encode(t, map[int8]string{1: "a", 2: "b"})
+	},
+	"Dict_IntKeys_Int64": func(t *testing.T) []byte {
+		return encode(t, map[int64]string{10: "a"})
+	},
+	"Dict_IntKeys_SLEB128": func(t *testing.T) []byte {
+		return encode(t, map[int]string{42: "x"})
+	},
+	"Tag_Padding_Read_Skipped": func(t *testing.T) []byte {
+		return []byte{0xFF, 0xFF, 0xFF, boolTrue}
+	},
+	"Tag_Count_Read": func(t *testing.T) []byte {
+		return []byte{tagCount, 0x05, listStart, listEnd}
+	},
+	"Tag_Size_String": func(t *testing.T) []byte {
+		return []byte{tagSize, 0x03, 'a', 'b', 'c'}
+	},
+	"Float16_Zero": func(t *testing.T) []byte {
+		return []byte{0xB8, 0x00, 0x00}
+	},
+	"Float16_NegZero": func(t *testing.T) []byte {
+		return []byte{0xB8, 0x00, 0x80}
+	},
+	"Float16_PosInf": func(t *testing.T) []byte {
+		return []byte{0xB8, 0x00, 0x7C}
+	},
+	"Float16_NaN": func(t *testing.T) []byte {
+		return []byte{0xB8, 0x00, 0x7E}
+	},
+	"Float16_Subnormal": func(t *testing.T) []byte {
+		return []byte{0xB8, 0x01, 0x00}
+	},
+	"Float16_Normal": func(t *testing.T) []byte {
+		return []byte{0xB8, 0x00, 0x3C}
+	},
+	"Float16_Negative": func(t *testing.T) []byte {
+		return []byte{0xB8, 0x00, 0xC0}
+	},
+	"Decoder_EmptyDict": func(t *testing.T) []byte {
+		return []byte{dictStart, dictEnd}
+	},
+	"Tag_Count_Decoder_Transparent": func(t *testing.T) []byte {
+		return []byte{tagCount, 0x03, listStart,
+			0xA1, 0xA2, 0xA3, // 1, 2, 3 inline
+			listEnd}
+	},
+	"DictKey_FixedKeyStartingWithFF": func(t *testing.T) []byte {
+		return []byte{dictStart,
+			typeUint64, 1, 0, 0, 0, 0, 0, 0, 0, 0xA1,
+			0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xA2,
+			dictEnd}
+	},
+	"Decoder_NestedDict": func(t *testing.T) []byte {
+		return encodeWith(t, &Encoder{Deterministic: true}, map[string]interface{}{
+			"inner": map[string]interface{}{"k": "v"},
+		})
+	},
+	"DictKey_TagLikeKeyFirst/127": func(t *testing.T) []byte {
+		return encode(t, Dict{{Key: 127, Value: "a"}, {Key: 1, Value: "b"}})
+	},
+	"DictKey_TagLikeKeyFirst/147": func(t *testing.T) []byte {
+		return encode(t, Dict{{Key: 147, Value: "a"}, {Key: 1, Value: "b"}})
+	},
+	"DictKey_TagLikeKeyFirst/-109": func(t *testing.T) []byte {
+		return encode(t, Dict{{Key: int8(-109), Value: "a"}, {Key: int8(1), Value: "b"}})
+	},
+}
+
 // ---------------------------------------------------------------------------
 // 1. Strings
 // ---------------------------------------------------------------------------
 
 func TestSpec_String_NullTerminated(t *testing.T) {
 	// Regular UTF-8 string: bytes + 0x00
-	data := encode(t, "hello")
+	data := specFixtures["String_NullTerminated"](t)
 	assert.Equal(t, append([]byte("hello"), 0x00), data)
 }
 
 func TestSpec_String_Empty(t *testing.T) {
 	// Empty string: just 0x00
-	data := encode(t, "")
+	data := specFixtures["String_Empty"](t)
 	assert.Equal(t, []byte{0x00}, data)
 }
 
 func TestSpec_String_ContainsNull(t *testing.T) {
 	// String with embedded null → fixed-length: 0x8B + ULEB128(len) + bytes
 	s := "te\x00st"
-	data := encode(t, s)
+	data := specFixtures["String_ContainsNull"](t)
 	assert.Equal(t, byte(tagSize), data[0], "must use size tag for string with null")
 	// round-trip
 	toks := tokens(t, data)
@@ -78,15 +228,28 @@ func TestSpec_String_ContainsNull(t *testing.T) {
 
 func TestSpec_String_Long(t *testing.T) {
 	// String >= 512 bytes → fixed-length
-	s := string(make([]byte, 512))
-	data := encode(t, s)
+	data := specFixtures["String_Long"](t)
 	assert.Equal(t, byte(tagSize), data[0], "must use size tag for string >= 512 bytes")
 }
 
+func TestSpec_String_InvalidUTF8(t *testing.T) {
+	// Strings that are not valid UTF-8 may start with a tag byte (list start,
+	// typed int, padding, …) and must be size-tagged to survive.
+	for _, s := range []string{"\x90", "\xB0\x01", "\xFFpad", "ok\xC0", "\xF8\x88\x80\x80\x80"} {
+		data := encode(t, s)
+		assert.Equal(t, byte(tagSize), data[0], "must use size tag for %q", s)
+		toks := tokens(t, data)
+		require.Len(t, toks, 1)
+		assert.Equal(t, Token{A: TokenString, Data: s}, toks[0])
+	}
+
+	// valid multi-byte UTF-8 keeps the null-terminated form
+	assert.Equal(t, []byte("żółw\x00"), encode(t, "żółw"))
+}
+
 func TestSpec_String_LRU_FirstOccurrence(t *testing.T) {
 	// 0x8C tag must precede the string on first LRU write
-	enc := &Encoder{LRU: true}
-	data := encodeWith(t, enc, "foo")
+	data := specFixtures["String_LRU_FirstOccurrence"](t)
 	assert.Equal(t, byte(tagRefString), data[0], "first LRU string must be preceded by 0x8C")
 	// the string itself follows
 	assert.Equal(t, []byte("foo"), data[1:4])
@@ -175,7 +338,7 @@ func TestSpec_Uint_SLEB128_RoundTrip(t *testing.T) {
 func TestSpec_TypedInt_Int8(t *testing.T) {
 	// Typed integers are only used inside TypedArrays (not standalone)
 	// 0xB0 = int8, 1 byte LE
-	data := encode(t, []int8{-4, -3, -2, -1, 0, 1, 2, 3, 4})
+	data := specFixtures["TypedInt_Int8"](t)
 	assert.Equal(t, byte(typedArray), data[0])
 	assert.Equal(t, byte(typeInt8), data[1])
 	// count = 9, then 9 raw bytes
@@ -184,14 +347,14 @@ func TestSpec_TypedInt_Int8(t *testing.T) {
 }
 
 func TestSpec_TypedInt_Uint8(t *testing.T) {
-	data := encode(t, []uint8{0, 1, 2, 3, 4})
+	data := specFixtures["TypedInt_Uint8"](t)
 	assert.Equal(t, byte(typedArray), data[0])
 	assert.Equal(t, byte(typeUint8), data[1])
 	assert.Equal(t, byte(5), data[2])
 }
 
 func TestSpec_TypedInt_Int32(t *testing.T) {
-	data := encode(t, []int32{-4, -3, -2, -1, 0, 1, 2, 3, 4})
+	data := specFixtures["TypedInt_Int32"](t)
 	assert.Equal(t, byte(typedArray), data[0])
 	assert.Equal(t, byte(typeInt32), data[1])
 	toks := tokens(t, data)
@@ -201,14 +364,14 @@ func TestSpec_TypedInt_Int32(t *testing.T) {
 }
 
 func TestSpec_TypedInt_Int64(t *testing.T) {
-	data := encode(t, []int64{-4, -3, -2, -1, 0, 1, 2, 3, 4})
+	data := specFixtures["TypedInt_Int64"](t)
 	toks := tokens(t, data)
 	require.Len(t, toks, 1)
 	assert.Equal(t, []int64{-4, -3, -2, -1, 0, 1, 2, 3, 4}, toks[0].Data)
 }
 
 func TestSpec_TypedFloat_Float32(t *testing.T) {
-	data := encode(t, []float32{1.2, 3.4, 5.6})
+	data := specFixtures["TypedFloat_Float32"](t)
 	assert.Equal(t, byte(typedArray), data[0])
 	assert.Equal(t, byte(typeFloat32), data[1])
 	toks := tokens(t, data)
@@ -219,7 +382,7 @@ func TestSpec_TypedFloat_Float32(t *testing.T) {
 }
 
 func TestSpec_TypedFloat_Float64(t *testing.T) {
-	data := encode(t, []float64{1.2, 3.4, 5.6})
+	data := specFixtures["TypedFloat_Float64"](t)
 	assert.Equal(t, byte(typedArray), data[0])
 	assert.Equal(t, byte(typeFloat64), data[1])
 	toks := tokens(t, data)
@@ -233,42 +396,42 @@ func TestSpec_TypedFloat_Float64(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestSpec_Float_F64_Pi(t *testing.T) {
-	data := encode(t, math.Pi)
+	data := specFixtures["Float_F64_Pi"](t)
 	assert.Equal(t, byte(floatF64), data[0])
 	toks := tokens(t, data)
 	assert.InDelta(t, math.Pi, toks[0].Data.(float64), 1e-12)
 }
 
 func TestSpec_Float_NaN(t *testing.T) {
-	data := encode(t, math.NaN())
+	data := specFixtures["Float_NaN"](t)
 	assert.Equal(t, []byte{nanValue}, data)
 	toks := tokens(t, data)
 	assert.True(t, math.IsNaN(toks[0].Data.(float64)))
 }
 
 func TestSpec_Float_NegInf(t *testing.T) {
-	data := encode(t, math.Inf(-1))
+	data := specFixtures["Float_NegInf"](t)
 	assert.Equal(t, []byte{negativeInfValue}, data)
 	toks := tokens(t, data)
 	assert.True(t, math.IsInf(toks[0].Data.(float64), -1))
 }
 
 func TestSpec_Float_PosInf(t *testing.T) {
-	data := encode(t, math.Inf(1))
+	data := specFixtures["Float_PosInf"](t)
 	assert.Equal(t, []byte{positiveInfValue}, data)
 }
 
 func TestSpec_Float_F32_StandaloneWrite(t *testing.T) {
 	// Spec: float32 is 0xB9 + 4 bytes. Writer uses float64 (0xBA) for all standalone floats.
 	// This test checks that a float32 value written standalone uses f64 encoding.
-	data := encode(t, float32(1.5))
+	data := specFixtures["Float_F32_StandaloneWrite"](t)
 	assert.Equal(t, byte(floatF64), data[0], "standalone float32 must be encoded as f64")
 }
 
 func TestSpec_Float_F16_Read(t *testing.T) {
 	// 0xB8 = float16 — reader must handle it (writer doesn't emit it)
 	// float16 of 1.0 = 0x3C00 (little-endian: 0x00, 0x3C)
-	f16data := []byte{0xB8, 0x00, 0x3C}
+	f16data := specFixtures["Float_F16_Read"](t)
 	toks := tokens(t, f16data)
 	require.Len(t, toks, 1)
 	assert.Equal(t, TokenFloat, toks[0].A, "0xB8 must produce TokenFloat")
@@ -343,7 +506,7 @@ func TestSpec_TypedArray_Chunked_Write(t *testing.T) {
 }
 
 func TestSpec_TypedArray_Empty(t *testing.T) {
-	data := encode(t, []int32{})
+	data := specFixtures["TypedArray_Empty"](t)
 	assert.Equal(t, byte(typedArray), data[0])
 	assert.Equal(t, byte(typeInt32), data[1])
 	assert.Equal(t, byte(0x00), data[2]) // count = 0
@@ -354,12 +517,12 @@ func TestSpec_TypedArray_Empty(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestSpec_List_Empty(t *testing.T) {
-	data := encode(t, []interface{}{})
+	data := specFixtures["List_Empty"](t)
 	assert.Equal(t, []byte{listStart, listEnd}, data)
 }
 
 func TestSpec_List_Mixed(t *testing.T) {
-	data := encode(t, []interface{}{"a", 1, true, nil})
+	data := specFixtures["List_Mixed"](t)
 	toks := tokens(t, data)
 	assert.Equal(t, TokenListStart, toks[0].A)
 	assert.Equal(t, TokenString, toks[1].A)
@@ -370,7 +533,7 @@ func TestSpec_List_Mixed(t *testing.T) {
 }
 
 func TestSpec_List_Nested(t *testing.T) {
-	data := encode(t, []interface{}{[]interface{}{}, []interface{}{}})
+	data := specFixtures["List_Nested"](t)
 	toks := tokens(t, data)
 	// outer [ inner[] inner[] ]
 	assert.Equal(t, TokenListStart, toks[0].A)
@@ -386,12 +549,12 @@ func TestSpec_List_Nested(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestSpec_Dict_Empty(t *testing.T) {
-	data := encode(t, map[string]int{})
+	data := specFixtures["Dict_Empty"](t)
 	assert.Equal(t, []byte{dictStart, dictEnd}, data)
 }
 
 func TestSpec_Dict_StringKeys(t *testing.T) {
-	data := encode(t, map[string]interface{}{"key": "val"})
+	data := specFixtures["Dict_StringKeys"](t)
 	toks := tokens(t, data)
 	assert.Equal(t, TokenDictStart, toks[0].A)
 	assert.Equal(t, TokenString, toks[1].A)
@@ -403,23 +566,20 @@ func TestSpec_Dict_StringKeys(t *testing.T) {
 
 func TestSpec_Dict_IntKeys_Int8(t *testing.T) {
 	// First key: 0xB0 (typeInt8) + value; subsequent keys: value only (no type prefix)
-	m := map[int8]string{1: "a", 2: "b"}
-	data := encode(t, m)
+	data := specFixtures["Dict_IntKeys_Int8"](t)
 	assert.Equal(t, byte(dictStart), data[0])
 	assert.Equal(t, byte(typeInt8), data[1], "first int key must have type prefix")
 }
 
 func TestSpec_Dict_IntKeys_Int64(t *testing.T) {
-	m := map[int64]string{10: "a"}
-	data := encode(t, m)
+	data := specFixtures["Dict_IntKeys_Int64"](t)
 	assert.Equal(t, byte(dictStart), data[0])
 	assert.Equal(t, byte(typeInt64), data[1])
 }
 
 func TestSpec_Dict_IntKeys_SLEB128(t *testing.T) {
 	// int/uint (platform-dependent) uses 0xBB prefix for first key
-	m := map[int]string{42: "x"}
-	data := encode(t, m)
+	data := specFixtures["Dict_IntKeys_SLEB128"](t)
 	assert.Equal(t, byte(dictStart), data[0])
 	assert.Equal(t, byte(0xBB), data[1])
 }
@@ -534,7 +694,7 @@ func TestSpec_Tag_Padding_Write(t *testing.T) {
 
 func TestSpec_Tag_Padding_Read_Skipped(t *testing.T) {
 	// Reader must skip 0xFF bytes before each token
-	data := []byte{0xFF, 0xFF, 0xFF, boolTrue}
+	data := specFixtures["Tag_Padding_Read_Skipped"](t)
 	toks := tokens(t, data)
 	require.Len(t, toks, 1)
 	assert.Equal(t, TokenTrue, toks[0].A)
@@ -542,7 +702,7 @@ func TestSpec_Tag_Padding_Read_Skipped(t *testing.T) {
 
 func TestSpec_Tag_Count_Read(t *testing.T) {
 	// 0x8A + ULEB128(n) → TokenCount{Data: n}
-	data := []byte{tagCount, 0x05, listStart, listEnd}
+	data := specFixtures["Tag_Count_Read"](t)
 	r := NewByteReader(data)
 	tok, err := r.Next()
 	require.NoError(t, err)
@@ -552,9 +712,7 @@ func TestSpec_Tag_Count_Read(t *testing.T) {
 
 func TestSpec_Tag_Count_Decoder_Transparent(t *testing.T) {
 	// Count tag must be transparent in the high-level Decoder
-	data := []byte{tagCount, 0x03, listStart,
-		0xA1, 0xA2, 0xA3, // 1, 2, 3 inline
-		listEnd}
+	data := specFixtures["Tag_Count_Decoder_Transparent"](t)
 	d := NewDecoder(data)
 	v, err := d.Decode()
 	require.NoError(t, err)
@@ -565,7 +723,7 @@ func TestSpec_Tag_Size_String(t *testing.T) {
 	// 0x8B = size tag for strings (already used for long/null strings)
 	s := "abc"
 	// manually construct: 0x8B + ULEB128(3) + "abc" — reader must handle it
-	data := []byte{tagSize, 0x03, 'a', 'b', 'c'}
+	data := specFixtures["Tag_Size_String"](t)
 	toks := tokens(t, data)
 	require.Len(t, toks, 1)
 	assert.Equal(t, TokenString, toks[0].A)
@@ -738,7 +896,7 @@ func TestSpec_Chaining_MagicMidStream(t *testing.T) {
 }
 
 func TestSpec_Decoder_NestedList(t *testing.T) {
-	data := encode(t, []interface{}{[]interface{}{"x"}, []interface{}{"y"}})
+	data := specFixtures["Decoder_NestedList"](t)
 	d := NewDecoder(data)
 	v, err := d.Decode()
 	require.NoError(t, err)
@@ -748,10 +906,7 @@ func TestSpec_Decoder_NestedList(t *testing.T) {
 }
 
 func TestSpec_Decoder_NestedDict(t *testing.T) {
-	enc := &Encoder{Deterministic: true}
-	data := encodeWith(t, enc, map[string]interface{}{
-		"inner": map[string]interface{}{"k": "v"},
-	})
+	data := specFixtures["Decoder_NestedDict"](t)
 	d := NewDecoder(data)
 	v, err := d.Decode()
 	require.NoError(t, err)
@@ -761,7 +916,7 @@ func TestSpec_Decoder_NestedDict(t *testing.T) {
 }
 
 func TestSpec_Decoder_TypedArray(t *testing.T) {
-	data := encode(t, []float64{1.1, 2.2, 3.3})
+	data := specFixtures["Decoder_TypedArray"](t)
 	d := NewDecoder(data)
 	v, err := d.Decode()
 	require.NoError(t, err)
@@ -903,7 +1058,7 @@ func TestSpec_MergeTypedSlices_AllTypes(t *testing.T) {
 
 func TestSpec_Float16_Zero(t *testing.T) {
 	// +0.0: bits = 0x0000
-	data := []byte{0xB8, 0x00, 0x00}
+	data := specFixtures["Float16_Zero"](t)
 	toks := tokens(t, data)
 	require.Len(t, toks, 1)
 	assert.Equal(t, float64(0), toks[0].Data.(float64))
@@ -911,7 +1066,7 @@ func TestSpec_Float16_Zero(t *testing.T) {
 
 func TestSpec_Float16_NegZero(t *testing.T) {
 	// -0.0: bits = 0x8000
-	data := []byte{0xB8, 0x00, 0x80}
+	data := specFixtures["Float16_NegZero"](t)
 	toks := tokens(t, data)
 	require.Len(t, toks, 1)
 	assert.Equal(t, math.Signbit(toks[0].Data.(float64)), true)
@@ -919,7 +1074,7 @@ func TestSpec_Float16_NegZero(t *testing.T) {
 
 func TestSpec_Float16_PosInf(t *testing.T) {
 	// +inf: exp=0x1F, mant=0 → bits = 0x7C00
-	data := []byte{0xB8, 0x00, 0x7C}
+	data := specFixtures["Float16_PosInf"](t)
 	toks := tokens(t, data)
 	require.Len(t, toks, 1)
 	assert.True(t, math.IsInf(toks[0].Data.(float64), 1))
@@ -927,7 +1082,7 @@ func TestSpec_Float16_PosInf(t *testing.T) {
 
 func TestSpec_Float16_NaN(t *testing.T) {
 	// NaN: exp=0x1F, mant≠0 → bits = 0x7E00
-	data := []byte{0xB8, 0x00, 0x7E}
+	data := specFixtures["Float16_NaN"](t)
 	toks := tokens(t, data)
 	require.Len(t, toks, 1)
 	assert.True(t, math.IsNaN(toks[0].Data.(float64)))
@@ -935,7 +1090,7 @@ func TestSpec_Float16_NaN(t *testing.T) {
 
 func TestSpec_Float16_Subnormal(t *testing.T) {
 	// Smallest positive subnormal: bits = 0x0001
-	data := []byte{0xB8, 0x01, 0x00}
+	data := specFixtures["Float16_Subnormal"](t)
 	toks := tokens(t, data)
 	require.Len(t, toks, 1)
 	v := toks[0].Data.(float64)
@@ -944,7 +1099,7 @@ func TestSpec_Float16_Subnormal(t *testing.T) {
 
 func TestSpec_Float16_Normal(t *testing.T) {
 	// 1.0 in float16: bits = 0x3C00
-	data := []byte{0xB8, 0x00, 0x3C}
+	data := specFixtures["Float16_Normal"](t)
 	toks := tokens(t, data)
 	require.Len(t, toks, 1)
 	assert.InDelta(t, 1.0, toks[0].Data.(float64), 1e-4)
@@ -952,7 +1107,7 @@ func TestSpec_Float16_Normal(t *testing.T) {
 
 func TestSpec_Float16_Negative(t *testing.T) {
 	// -2.0 in float16: bits = 0xC000
-	data := []byte{0xB8, 0x00, 0xC0}
+	data := specFixtures["Float16_Negative"](t)
 	toks := tokens(t, data)
 	require.Len(t, toks, 1)
 	assert.InDelta(t, -2.0, toks[0].Data.(float64), 1e-4)
@@ -1011,6 +1166,29 @@ func TestSpec_DictKey_MultipleIntKeys_SLEB128(t *testing.T) {
 	assert.Equal(t, "c", result[30])
 }
 
+func TestSpec_DictKey_FixedKeyStartingWithFF(t *testing.T) {
+	// a raw uint64 key after the first may start with 0xFF; it is key data,
+	// not padding
+	data := specFixtures["DictKey_FixedKeyStartingWithFF"](t)
+	var out map[uint64]int
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, map[uint64]int{1: 1, math.MaxUint64: 2}, out)
+}
+
+func TestSpec_DictKey_TagLikeKeyFirst(t *testing.T) {
+	// without a type byte 127 reads as padding (ff 00) and 147 and int8 -109
+	// as the dict end (0x93), so they can only be the first key
+	for _, key := range []interface{}{127, 147, int8(-109)} {
+		name := fmt.Sprint("DictKey_TagLikeKeyFirst/", key)
+		d := NewDecoder(specFixtures[name](t))
+		d.OrderedDicts = true
+		v, err := d.Decode()
+		require.NoError(t, err, name)
+		require.Len(t, v, 2, name)
+		assert.Equal(t, DictEntry{Key: 1, Value: "b"}, v.(Dict)[1], name)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // 17. writeMap error paths
 // ---------------------------------------------------------------------------
@@ -1054,7 +1232,7 @@ func TestSpec_WriteChunkedTypedArray_NonSliceError(t *testing.T) {
 // ---------------------------------------------------------------------------
 
 func TestSpec_Decoder_EmptyDict(t *testing.T) {
-	data := []byte{dictStart, dictEnd}
+	data := specFixtures["Decoder_EmptyDict"](t)
 	d := NewDecoder(data)
 	v, err := d.Decode()
 	require.NoError(t, err)
@@ -0,0 +1,127 @@
+package muon
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+)
+
+// WriteListFrom writes a list whose elements are produced by next, one call
+// per element, until next returns false. Each element is encoded as soon as
+// it is produced, so a list of any length is written without holding it in
+// memory; LRU and Deterministic apply as for [Encoder.Write].
+//
+// An error from next or from encoding an element ends the list early and is
+// returned; the output is then incomplete.
+func (e *Encoder) WriteListFrom(w io.Writer, next func() (interface{}, bool, error)) error {
+	if err := e.writeByte(w, listStart); err != nil {
+		return err
+	}
+	for {
+		v, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if err := e.writeValue(w, reflect.ValueOf(v)); err != nil {
+			return err
+		}
+	}
+	return e.writeByte(w, listEnd)
+}
+
+// WriteListFromChan writes a list of the values received from ch until it
+// is closed. See [Encoder.WriteListFrom].
+func (e *Encoder) WriteListFromChan(w io.Writer, ch <-chan interface{}) error {
+	return e.WriteListFrom(w, func() (interface{}, bool, error) {
+		v, ok := <-ch
+		return v, ok, nil
+	})
+}
+
+// WriteDictFrom writes a dict whose entries are produced by next, one call
+// per entry, until next returns false. Keys are strings or integers, not
+// both in one dict; integer keys are written as SLEB128 since their range
+// is not known in advance. Only the first key can be any integer: a later
+// one whose SLEB128 form starts with 0x93 or 0xFF, such as 127, 147 or
+// math.MaxUint64, would read back as the dict end or as padding and is an
+// error. Under Deterministic the keys must arrive in
+// sorted order (strings in byte order, integers by value) without repeats,
+// or an error is returned. See [Encoder.WriteListFrom].
+func (e *Encoder) WriteDictFrom(w io.Writer, next func() (key, value interface{}, ok bool, err error)) error {
+	if err := e.writeByte(w, dictStart); err != nil {
+		return err
+	}
+	var (
+		first      = true
+		isString   bool
+		firstKey   interface{}
+		prevString string
+		prevInt    *big.Int
+	)
+	for {
+		key, value, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		s, keyIsString := key.(string)
+		var x *big.Int
+		if !keyIsString {
+			if x, ok = keyInt(key); !ok {
+				return fmt.Errorf("dict keys must be string or integer, got %T", key)
+			}
+		}
+		if first {
+			isString, firstKey = keyIsString, key
+		} else if keyIsString != isString {
+			return fmt.Errorf("mixed dict key types: %T and %T", firstKey, key)
+		}
+		if e.Deterministic && !first {
+			if isString && s <= prevString || !isString && x.Cmp(prevInt) <= 0 {
+				return fmt.Errorf("dict key %v out of order under Deterministic", key)
+			}
+		}
+
+		if isString {
+			err = e.writeString(w, s)
+			prevString = s
+		} else {
+			b := e.scratch[:0]
+			if first {
+				b = append(b, 0xBB)
+			}
+			b = appendBigSleb128(b, x)
+			// a later key has no type byte, so it must not read as the end
+			// of the dict or as padding
+			if !first && (b[0] == dictEnd || b[0] == tagPadding) {
+				return fmt.Errorf("dict key %v cannot be encoded unambiguously", key)
+			}
+			err = e.flushScratch(w, b)
+			prevInt = x
+		}
+		if err != nil {
+			return err
+		}
+		if err := e.writeValue(w, reflect.ValueOf(value)); err != nil {
+			return err
+		}
+		first = false
+	}
+	return e.writeByte(w, dictEnd)
+}
+
+// WriteDictFromChan writes a dict of the entries received from ch until it
+// is closed. See [Encoder.WriteDictFrom].
+func (e *Encoder) WriteDictFromChan(w io.Writer, ch <-chan DictEntry) error {
+	return e.WriteDictFrom(w, func() (interface{}, interface{}, bool, error) {
+		entry, ok := <-ch
+		return entry.Key, entry.Value, ok, nil
+	})
+}
@@ -0,0 +1,132 @@
+package muon
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counter returns a WriteListFrom source producing 0…n-1.
+func counter(n int) func() (interface{}, bool, error) {
+	i := 0
+	return func() (interface{}, bool, error) {
+		if i == n {
+			return nil, false, nil
+		}
+		i++
+		return i - 1, true, nil
+	}
+}
+
+func TestWriteListFrom(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, (&Encoder{}).WriteListFrom(&buf, counter(1000)))
+	want := make([]int, 1000)
+	for i := range want {
+		want[i] = i
+	}
+	assert.Equal(t, encode(t, want), buf.Bytes())
+
+	t.Run("chan", func(t *testing.T) {
+		ch := make(chan interface{})
+		go func() {
+			for _, v := range []interface{}{"a", 1, nil, []string{"b"}} {
+				ch <- v
+			}
+			close(ch)
+		}()
+		var buf bytes.Buffer
+		require.NoError(t, (&Encoder{}).WriteListFromChan(&buf, ch))
+		assert.Equal(t, encode(t, []interface{}{"a", 1, nil, []string{"b"}}), buf.Bytes())
+	})
+
+	t.Run("shares LRU", func(t *testing.T) {
+		enc := &Encoder{LRU: true}
+		var buf bytes.Buffer
+		require.NoError(t, enc.Write(&buf, "row"))
+		ch := make(chan interface{}, 2)
+		ch <- "row"
+		ch <- "row"
+		close(ch)
+		require.NoError(t, enc.WriteListFromChan(&buf, ch))
+		assert.Equal(t, []byte{tagRefString, 'r', 'o', 'w', 0x00, listStart, stringRef, 0x00, stringRef, 0x00, listEnd}, buf.Bytes())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		boom := errors.New("boom")
+		err := (&Encoder{}).WriteListFrom(&bytes.Buffer{}, func() (interface{}, bool, error) {
+			return nil, false, boom
+		})
+		assert.ErrorIs(t, err, boom)
+	})
+}
+
+func TestWriteDictFrom(t *testing.T) {
+	entries := Dict{{Key: "b", Value: 1}, {Key: "a", Value: []int{2}}}
+	next := func(d Dict) func() (interface{}, interface{}, bool, error) {
+		return func() (interface{}, interface{}, bool, error) {
+			if len(d) == 0 {
+				return nil, nil, false, nil
+			}
+			e := d[0]
+			d = d[1:]
+			return e.Key, e.Value, true, nil
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, (&Encoder{}).WriteDictFrom(&buf, next(entries)))
+	assert.Equal(t, encode(t, entries), buf.Bytes())
+
+	t.Run("int keys", func(t *testing.T) {
+		var buf bytes.Buffer
+		in := Dict{{Key: int8(-3), Value: "x"}, {Key: 5, Value: "z"}, {Key: uint64(1 << 63), Value: "y"}}
+		require.NoError(t, (&Encoder{Deterministic: true}).WriteDictFrom(&buf, next(in)))
+		var out map[interface{}]string
+		require.NoError(t, Unmarshal(buf.Bytes(), &out))
+		assert.Equal(t, map[interface{}]string{-3: "x", uint64(1 << 63): "y", 5: "z"}, out)
+
+		// the first key carries a type byte, so any integer works there
+		for _, key := range []interface{}{127, 147, uint64(math.MaxUint64)} {
+			buf.Reset()
+			require.NoError(t, (&Encoder{}).WriteDictFrom(&buf, next(Dict{{Key: key, Value: "a"}, {Key: 1, Value: "b"}})))
+			var out map[interface{}]string
+			require.NoError(t, Unmarshal(buf.Bytes(), &out), "%v", key)
+			assert.Len(t, out, 2, "%v", key)
+			assert.Equal(t, "a", out[key], "%v", key)
+		}
+	})
+
+	t.Run("chan", func(t *testing.T) {
+		ch := make(chan DictEntry, len(entries))
+		for _, e := range entries {
+			ch <- e
+		}
+		close(ch)
+		var buf bytes.Buffer
+		require.NoError(t, (&Encoder{}).WriteDictFromChan(&buf, ch))
+		assert.Equal(t, encode(t, entries), buf.Bytes())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			enc *Encoder
+			in  Dict
+		}{
+			"unordered":    {&Encoder{Deterministic: true}, entries},
+			"repeated":     {&Encoder{Deterministic: true}, Dict{{Key: 1}, {Key: int8(1)}}},
+			"mixed keys":   {&Encoder{}, Dict{{Key: "a"}, {Key: 1}}},
+			"bad key type": {&Encoder{}, Dict{{Key: 1.5}}},
+			// later keys reading as padding (ff 00, ff … 01) or dict end (93 01)
+			"key 127": {&Encoder{}, Dict{{Key: 1}, {Key: 127}}},
+			"key 147": {&Encoder{}, Dict{{Key: 1}, {Key: 147}}},
+			"key max": {&Encoder{}, Dict{{Key: 1}, {Key: uint64(math.MaxUint64)}}},
+		} {
+			assert.Error(t, tc.enc.WriteDictFrom(&bytes.Buffer{}, next(tc.in)), name)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package muon
+
+import (
+	"io"
+	"reflect"
+	"time"
+)
+
+// TimeFormat selects how time.Time and time.Duration values are represented
+// on the wire. It can be set for a whole [Encoder] or [Decoder], and
+// overridden per struct field with the rfc3339, unixnano or unixms tag
+// options:
+//
+//	type Event struct {
+//	    At    time.Time     `muon:"at,unixms"`
+//	    Took  time.Duration `muon:"took,rfc3339"`
+//	}
+type TimeFormat int
+
+const (
+	// TimeFormatDefault writes time.Time as an RFC 3339 string with
+	// nanoseconds and time.Duration as integer nanoseconds.
+	TimeFormatDefault TimeFormat = iota
+	// TimeFormatRFC3339 writes time.Time as an RFC 3339 string with
+	// nanoseconds and the zone offset, and time.Duration in the form produced
+	// by [time.Duration.String] (e.g. "1h2m0.5s").
+	TimeFormatRFC3339
+	// TimeFormatUnixNano writes both types as integer nanoseconds; time.Time
+	// counts from the Unix epoch.
+	TimeFormatUnixNano
+	// TimeFormatUnixMilli writes both types as integer milliseconds; time.Time
+	// counts from the Unix epoch. Sub-millisecond precision is dropped.
+	TimeFormatUnixMilli
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+
+	timeTagFormats = map[string]TimeFormat{
+		"rfc3339":  TimeFormatRFC3339,
+		"unixnano": TimeFormatUnixNano,
+		"unixms":   TimeFormatUnixMilli,
+	}
+)
+
+// isTimeType reports whether t is time.Time or time.Duration.
+func isTimeType(t reflect.Type) bool {
+	return t == timeType || t == durationType
+}
+
+// writeTime encodes rv, a time.Time or time.Duration.
+func (e *Encoder) writeTime(w io.Writer, rv reflect.Value, f TimeFormat) error {
+	if rv.Type() == durationType {
+		d := time.Duration(rv.Int())
+		switch f {
+		case TimeFormatRFC3339:
+			return e.writeString(w, d.String())
+		case TimeFormatUnixMilli:
+			return e.writeInt64(w, d.Milliseconds())
+		}
+		return e.writeInt64(w, int64(d))
+	}
+	t := rv.Interface().(time.Time)
+	switch f {
+	case TimeFormatUnixNano:
+		return e.writeInt64(w, t.UnixNano())
+	case TimeFormatUnixMilli:
+		return e.writeInt64(w, t.UnixMilli())
+	}
+	return e.writeString(w, t.Format(time.RFC3339Nano))
+}
+
+// unmarshalTime decodes tok into v, a time.Time or time.Duration. Strings are
+// always parsed (RFC 3339 / duration syntax); integers are read as
+// milliseconds under [TimeFormatUnixMilli] and as nanoseconds otherwise.
+func (d *Decoder) unmarshalTime(tok Token, v reflect.Value, f TimeFormat) error {
+	if v.Type() == durationType {
+		switch tok.A {
+		case TokenString:
+			dur, err := time.ParseDuration(tok.Data.(string))
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(dur))
+			return nil
+		case TokenInt:
+			n, err := toInt64(tok.Data)
+			if err != nil {
+				return err
+			}
+			if f == TimeFormatUnixMilli {
+				n *= int64(time.Millisecond)
+			}
+			v.SetInt(n)
+			return nil
+		}
+		return errTypeMismatch(tok.A, v.Interface())
+	}
+	switch tok.A {
+	case TokenString:
+		t, err := time.Parse(time.RFC3339Nano, tok.Data.(string))
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case TokenInt:
+		n, err := toInt64(tok.Data)
+		if err != nil {
+			return err
+		}
+		t := time.Unix(0, n)
+		if f == TimeFormatUnixMilli {
+			t = time.UnixMilli(n)
+		}
+		v.Set(reflect.ValueOf(t.UTC()))
+		return nil
+	}
+	return errTypeMismatch(tok.A, v.Interface())
+}
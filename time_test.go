@@ -0,0 +1,167 @@
+package muon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTime_DefaultIsRFC3339(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.FixedZone("", 2*3600))
+	assert.Equal(t, append([]byte("2024-03-01T12:30:45.123456789+02:00"), stringEnd), encode(t, ts))
+
+	var out time.Time
+	require.NoError(t, Unmarshal(encode(t, ts), &out))
+	assert.True(t, ts.Equal(out))
+	assert.Equal(t, ts.Nanosecond(), out.Nanosecond())
+	_, offset := out.Zone()
+	assert.Equal(t, 2*3600, offset)
+}
+
+func TestTime_EncoderFormats(t *testing.T) {
+	ts := time.Unix(1700000000, 5000000).UTC()
+	cases := map[TimeFormat]interface{}{
+		TimeFormatRFC3339:   "2023-11-14T22:13:20.005Z",
+		TimeFormatUnixNano:  int64(1700000000005000000),
+		TimeFormatUnixMilli: int64(1700000000005),
+	}
+	for f, wire := range cases {
+		enc := &Encoder{TimeFormat: f}
+		data := encodeWith(t, enc, ts)
+		assert.Equal(t, encode(t, wire), data)
+
+		d := NewDecoder(data)
+		d.TimeFormat = f
+		var out time.Time
+		require.NoError(t, d.Unmarshal(&out))
+		assert.True(t, ts.Equal(out), "format %d", f)
+	}
+}
+
+func TestTime_Pointers(t *testing.T) {
+	ts := time.Unix(1700000000, 5000000).UTC()
+	dur := 1500 * time.Millisecond
+	enc := &Encoder{TimeFormat: TimeFormatUnixNano}
+
+	assert.Equal(t, encode(t, ts.UnixNano()), encodeWith(t, enc, &ts))
+	assert.Equal(t, encode(t, []interface{}{ts.UnixNano(), nil}), encodeWith(t, enc, []*time.Time{&ts, nil}))
+	assert.Equal(t, encode(t, "1.5s"), encodeWith(t, &Encoder{TimeFormat: TimeFormatRFC3339}, &dur))
+
+	type S struct {
+		At *time.Time `muon:"at"`
+	}
+	data := encodeWith(t, enc, S{At: &ts})
+	assert.Equal(t, encode(t, Dict{{Key: "at", Value: ts.UnixNano()}}), data)
+
+	d := NewDecoder(data)
+	d.TimeFormat = TimeFormatUnixNano
+	var out S
+	require.NoError(t, d.Unmarshal(&out))
+	require.NotNil(t, out.At)
+	assert.True(t, ts.Equal(*out.At))
+}
+
+func TestTime_Duration(t *testing.T) {
+	dur := 90*time.Minute + 500*time.Millisecond
+
+	assert.Equal(t, encode(t, int64(dur)), encode(t, dur))
+	assert.Equal(t, encode(t, "1h30m0.5s"), encodeWith(t, &Encoder{TimeFormat: TimeFormatRFC3339}, dur))
+	assert.Equal(t, encode(t, int64(5400500)), encodeWith(t, &Encoder{TimeFormat: TimeFormatUnixMilli}, dur))
+
+	var out time.Duration
+	require.NoError(t, Unmarshal(encode(t, int64(dur)), &out))
+	assert.Equal(t, dur, out)
+	require.NoError(t, Unmarshal(encode(t, "1h30m0.5s"), &out))
+	assert.Equal(t, dur, out)
+}
+
+func TestTime_DurationSlice(t *testing.T) {
+	in := []time.Duration{time.Second, 1500 * time.Millisecond}
+	cases := map[TimeFormat]interface{}{
+		TimeFormatDefault:   []int64{1000000000, 1500000000},
+		TimeFormatRFC3339:   []string{"1s", "1.5s"},
+		TimeFormatUnixNano:  []int64{1000000000, 1500000000},
+		TimeFormatUnixMilli: []int64{1000, 1500},
+	}
+	for f, wire := range cases {
+		enc := &Encoder{TimeFormat: f}
+		data := encodeWith(t, enc, in)
+		assert.Equal(t, encodeWith(t, &Encoder{DisableTypedArrays: true}, wire), data, "format %d", f)
+
+		d := NewDecoder(data)
+		d.TimeFormat = f
+		var out []time.Duration
+		require.NoError(t, d.Unmarshal(&out))
+		assert.Equal(t, in, out, "format %d", f)
+	}
+
+	type S struct {
+		Took  []time.Duration  `muon:"took,unixms"`
+		Text  *[]time.Duration `muon:"text,rfc3339"`
+		Times [2]time.Time     `muon:"times,unixms"`
+	}
+	ts := time.UnixMilli(1700000000005).UTC()
+	s := S{Took: in, Text: &in, Times: [2]time.Time{ts, ts.Add(time.Second)}}
+	data := encode(t, s)
+	assert.Equal(t, encode(t, Dict{
+		{Key: "took", Value: []interface{}{1000, 1500}},
+		{Key: "text", Value: []interface{}{"1s", "1.5s"}},
+		{Key: "times", Value: []interface{}{ts.UnixMilli(), ts.Add(time.Second).UnixMilli()}},
+	}), data)
+
+	var out S
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out.Took)
+	require.NotNil(t, out.Text)
+	assert.Equal(t, in, *out.Text)
+	assert.True(t, ts.Equal(out.Times[0]))
+	assert.True(t, ts.Add(time.Second).Equal(out.Times[1]))
+}
+
+func TestTime_FieldTags(t *testing.T) {
+	type Event struct {
+		Nano    time.Time      `muon:"nano,unixnano"`
+		Milli   time.Time      `muon:"milli,unixms"`
+		Text    *time.Time     `muon:"text,rfc3339"`
+		Took    time.Duration  `muon:"took,unixms"`
+		TookStr *time.Duration `muon:"took_str,rfc3339"`
+		Plain   time.Time      `muon:"plain"`
+	}
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 1, time.UTC)
+	dur := 3 * time.Second
+	in := Event{Nano: ts, Milli: ts, Text: &ts, Took: dur, TookStr: &dur, Plain: ts}
+
+	toks := tokens(t, encode(t, in))
+	require.Len(t, toks, 14)
+	assert.Equal(t, Token{A: TokenInt, Data: int(ts.UnixNano())}, toks[2])
+	assert.Equal(t, Token{A: TokenInt, Data: int(ts.UnixMilli())}, toks[4])
+	assert.Equal(t, Token{A: TokenString, Data: "2024-03-01T12:00:00.000000001Z"}, toks[6])
+	assert.Equal(t, Token{A: TokenInt, Data: 3000}, toks[8])
+	assert.Equal(t, Token{A: TokenString, Data: "3s"}, toks[10])
+	assert.Equal(t, Token{A: TokenString, Data: "2024-03-01T12:00:00.000000001Z"}, toks[12])
+
+	var out Event
+	require.NoError(t, Unmarshal(encode(t, in), &out))
+	assert.True(t, ts.Equal(out.Nano))
+	assert.True(t, ts.Truncate(time.Millisecond).Equal(out.Milli))
+	require.NotNil(t, out.Text)
+	assert.True(t, ts.Equal(*out.Text))
+	assert.Equal(t, dur, out.Took)
+	require.NotNil(t, out.TookStr)
+	assert.Equal(t, dur, *out.TookStr)
+	assert.True(t, ts.Equal(out.Plain))
+}
+
+func TestTime_Errors(t *testing.T) {
+	var ts time.Time
+	assert.Error(t, Unmarshal(encode(t, "yesterday"), &ts))
+	err := Unmarshal(encode(t, true), &ts)
+	me, ok := err.(MuonError)
+	require.True(t, ok)
+	assert.Equal(t, ErrCodeTypeMismatch, me.Code)
+
+	var dur time.Duration
+	assert.Error(t, Unmarshal(encode(t, "soon"), &dur))
+}
@@ -33,7 +33,8 @@ const (
 	TokenDictStart TokenEnum = "dict_start"
 	// TokenDictEnd marks the end of a dict.
 	TokenDictEnd TokenEnum = "dict_end"
-	// TokenInt is an integer value. Token.Data holds int, int64, or uint64.
+	// TokenInt is an integer value. Token.Data holds int, int64, or uint64, or
+	// *big.Int for SLEB128 values beyond 64 bits.
 	TokenInt TokenEnum = "int"
 )
 
@@ -2,15 +2,19 @@ package muon
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
-
-	"github.com/oherych/muon/internal"
 )
 
 // Unmarshal decodes the muon-encoded data into the value pointed to by target.
 // target must be a non-nil pointer. Supported target types mirror the encoding
 // side: bool, all int/uint/float sizes, string, slice, array, map, struct, and
 // pointer. Use *interface{} to decode interface{} value without a known schema.
+//
+// Targets implementing [encoding.TextUnmarshaler] accept strings, and targets
+// implementing [encoding.BinaryUnmarshaler] accept uint8 TypedArrays (and
+// strings, when they are not also a TextUnmarshaler).
 func Unmarshal(data []byte, target interface{}) error {
 	d := NewDecoder(data)
 	return d.Unmarshal(target)
@@ -19,73 +23,38 @@ func Unmarshal(data []byte, target interface{}) error {
 // Unmarshal reads the next value from the stream and stores it into target.
 // target must be a non-nil pointer.
 func (d *Decoder) Unmarshal(target interface{}) error {
-	rv := reflect.ValueOf(target)
-	if rv.Kind() != reflect.Ptr || rv.IsNil() {
-		return errInvalidTarget("target must be a non-nil pointer")
+	v, err := targetValue(target)
+	if err != nil {
+		return err
 	}
 	tok, err := d.r.Next()
 	if err != nil {
 		return err
 	}
-	return d.unmarshalToken(tok, rv.Elem())
-}
-
-func (d *Decoder) unmarshalToken(tok Token, v reflect.Value) error {
-	// transparently skip magic and count tags
-	if tok.A == TokenMagic || tok.A == TokenCount {
-		next, err := d.r.Next()
-		if err != nil {
-			return err
-		}
-		return d.unmarshalToken(next, v)
-	}
-
-	// nil token → zero the target
-	if tok.A == TokenNil {
-		v.Set(reflect.Zero(v.Type()))
-		return nil
+	if err := d.unmarshalToken(tok, v); err != nil {
+		return err
 	}
-
-	// pointer: allocate if nil, then unwrap
-	if v.Kind() == reflect.Ptr {
-		if v.IsNil() {
-			v.Set(reflect.New(v.Type().Elem()))
+	if d.RejectTrailingData {
+		d.r.skipPadding()
+		if rest := len(d.r.in) - d.r.scanp; rest > 0 {
+			return errTrailingData(d.r.scanp, rest)
 		}
-		return d.unmarshalToken(tok, v.Elem())
 	}
+	return nil
+}
 
-	// interface{}: use the high-level tokenToValue path
-	if v.Kind() == reflect.Interface {
-		val, err := d.tokenToValue(tok)
-		if err != nil {
-			return err
-		}
-		if val == nil {
-			v.Set(reflect.Zero(v.Type()))
-		} else {
-			v.Set(reflect.ValueOf(val))
-		}
-		return nil
+// targetValue returns the value target points to, or an error unless target
+// is a non-nil pointer.
+func targetValue(target interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return reflect.Value{}, errInvalidTarget("target must be a non-nil pointer")
 	}
+	return rv.Elem(), nil
+}
 
-	switch tok.A {
-	case TokenTrue, TokenFalse:
-		return d.unmarshalBool(tok, v)
-	case TokenInt:
-		return d.unmarshalInt(tok, v)
-	case TokenFloat:
-		return d.unmarshalFloat(tok, v)
-	case TokenString:
-		return d.unmarshalString(tok, v)
-	case TokenTypedArray:
-		return d.unmarshalTypedArray(tok, v)
-	case TokenListStart:
-		return d.unmarshalList(v)
-	case TokenDictStart:
-		return d.unmarshalDict(v)
-	default:
-		return errUnexpectedToken(tok.A)
-	}
+func (d *Decoder) unmarshalToken(tok Token, v reflect.Value) error {
+	return typeDecoder(v.Type(), d.Naming)(d, tok, v)
 }
 
 func (d *Decoder) unmarshalBool(tok Token, v reflect.Value) error {
@@ -140,11 +109,9 @@ func (d *Decoder) unmarshalTypedArray(tok Token, v reflect.Value) error {
 			// attempt element-wise conversion
 			out := reflect.MakeSlice(v.Type(), src.Len(), src.Len())
 			for i := 0; i < src.Len(); i++ {
-				elem := src.Index(i)
-				if !elem.Type().ConvertibleTo(v.Type().Elem()) {
-					return errTypeMismatch(tok.A, v.Interface())
+				if err := setTypedElem(out.Index(i), src.Index(i)); err != nil {
+					return err
 				}
-				out.Index(i).Set(elem.Convert(v.Type().Elem()))
 			}
 			v.Set(out)
 			return nil
@@ -153,167 +120,75 @@ func (d *Decoder) unmarshalTypedArray(tok Token, v reflect.Value) error {
 		return nil
 	case reflect.Array:
 		n := v.Len()
+		if d.StrictArrayLength {
+			if src.Len() > n {
+				return errArrayTooLong(v.Type())
+			}
+			if src.Len() < n {
+				return errArrayTooShort(v.Type(), src.Len())
+			}
+		}
 		if src.Len() < n {
 			n = src.Len()
 		}
 		for i := 0; i < n; i++ {
-			elem := src.Index(i)
-			if !elem.Type().ConvertibleTo(v.Type().Elem()) {
-				return errTypeMismatch(tok.A, v.Interface())
+			if err := setTypedElem(v.Index(i), src.Index(i)); err != nil {
+				return err
 			}
-			v.Index(i).Set(elem.Convert(v.Type().Elem()))
 		}
 		return nil
 	}
 	return errTypeMismatch(tok.A, v.Interface())
 }
 
-func (d *Decoder) unmarshalList(v reflect.Value) error {
-	switch v.Kind() {
-	case reflect.Slice:
-		elemType := v.Type().Elem()
-		for {
-			tok, err := d.r.Next()
-			if err != nil {
-				return err
-			}
-			if tok.A == TokenListEnd {
-				break
-			}
-			elem := reflect.New(elemType).Elem()
-			if err := d.unmarshalToken(tok, elem); err != nil {
-				return err
-			}
-			v.Set(reflect.Append(v, elem))
-		}
-		return nil
-	case reflect.Array:
-		i := 0
-		for {
-			tok, err := d.r.Next()
-			if err != nil {
-				return err
-			}
-			if tok.A == TokenListEnd {
-				break
-			}
-			if i < v.Len() {
-				if err := d.unmarshalToken(tok, v.Index(i)); err != nil {
-					return err
-				}
-			}
-			i++
+// setTypedElem stores the TypedArray element src into dst, converting it to
+// the type of dst. Integers must fit that type; floats, [Float16] included,
+// do not convert to integers, as scalar floats do not.
+func setTypedElem(dst, src reflect.Value) error {
+	t := dst.Type()
+	if src.Type() == float16Type {
+		src = reflect.ValueOf(Float16(src.Uint()).Float64())
+	}
+	if t == float16Type {
+		if k := src.Kind(); k != reflect.Float32 && k != reflect.Float64 {
+			return errTypeMismatch(TokenTypedArray, dst.Interface())
 		}
+		dst.SetUint(uint64(float64ToFloat16(src.Float())))
 		return nil
 	}
-	return errTypeMismatch(TokenListStart, v.Interface())
-}
-
-func (d *Decoder) unmarshalDict(v reflect.Value) error {
-	switch v.Kind() {
-	case reflect.Struct:
-		return d.unmarshalStruct(v)
-	case reflect.Map:
-		return d.unmarshalMap(v)
+	if !src.Type().ConvertibleTo(t) || dst.Kind() == reflect.String || dst.Kind() == reflect.Bool {
+		return errTypeMismatch(TokenTypedArray, dst.Interface())
 	}
-	return errTypeMismatch(TokenDictStart, v.Interface())
-}
-
-func (d *Decoder) unmarshalStruct(v reflect.Value) error {
-	// build field name → index map using muon tags
-	t := v.Type()
-	fields := make(map[string]int, t.NumField())
-	for i := 0; i < t.NumField(); i++ {
-		tf := t.Field(i)
-		if !tf.IsExported() {
-			continue
-		}
-		info := internal.ParseTags(tf)
-		if info.Skip {
-			continue
-		}
-		fields[info.Name] = i
-	}
-
-	for {
-		keyTok, err := d.r.Next()
-		if err != nil {
-			return err
-		}
-		if keyTok.A == TokenDictEnd {
-			return nil
-		}
-		if keyTok.A != TokenString {
-			return errUnexpectedToken(keyTok.A)
-		}
-		key := keyTok.Data.(string)
-
-		idx, ok := fields[key]
-		if !ok {
-			// unknown field: read and discard the value
-			if err := d.skipValue(); err != nil {
-				return err
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch src.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if dst.OverflowInt(src.Int()) {
+				return errOverflow(src.Int(), t)
 			}
-			continue
-		}
-		valTok, err := d.r.Next()
-		if err != nil {
-			return err
-		}
-		if err := d.unmarshalToken(valTok, v.Field(idx)); err != nil {
-			return err
-		}
-	}
-}
-
-func (d *Decoder) unmarshalMap(v reflect.Value) error {
-	if v.IsNil() {
-		v.Set(reflect.MakeMap(v.Type()))
-	}
-	keyType := v.Type().Key()
-	elemType := v.Type().Elem()
-
-	intKeyType := byte(0)
-	first := true
-
-	for {
-		var keyTok Token
-		var err error
-		if first {
-			keyTok, err = d.r.Next()
-			first = false
-		} else if intKeyType != 0 {
-			keyTok, err = d.r.NextIntKey(intKeyType)
-		} else {
-			keyTok, err = d.r.Next()
-		}
-		if err != nil {
-			return err
-		}
-		if keyTok.A == TokenDictEnd {
-			return nil
-		}
-
-		// remember int key type for subsequent keys
-		if keyTok.A == TokenInt && intKeyType == 0 {
-			intKeyType = d.r.lastIntKeyType
-		}
-
-		keyVal := reflect.New(keyType).Elem()
-		if err := d.unmarshalToken(keyTok, keyVal); err != nil {
-			return err
-		}
-
-		valTok, err := d.r.Next()
-		if err != nil {
-			return err
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if u := src.Uint(); u > math.MaxInt64 || dst.OverflowInt(int64(u)) {
+				return errOverflow(u, t)
+			}
+		case reflect.Float32, reflect.Float64:
+			return errTypeMismatch(TokenTypedArray, dst.Interface())
 		}
-		elemVal := reflect.New(elemType).Elem()
-		if err := d.unmarshalToken(valTok, elemVal); err != nil {
-			return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch src.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n := src.Int(); n < 0 || dst.OverflowUint(uint64(n)) {
+				return errOverflow(n, t)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if dst.OverflowUint(src.Uint()) {
+				return errOverflow(src.Uint(), t)
+			}
+		case reflect.Float32, reflect.Float64:
+			return errTypeMismatch(TokenTypedArray, dst.Interface())
 		}
-		v.SetMapIndex(keyVal, elemVal)
 	}
+	dst.Set(src.Convert(t))
+	return nil
 }
 
 // skipValue reads and discards the next complete value (including nested structures).
@@ -322,31 +197,50 @@ func (d *Decoder) skipValue() error {
 	if err != nil {
 		return err
 	}
+	return d.skipValueFrom(tok)
+}
+
+// skipValueFrom discards the rest of the value that starts with tok. Dict
+// keys are read as the decoders read them: after the first key of an
+// int-keyed dict they are raw bytes, not tokens.
+func (d *Decoder) skipValueFrom(tok Token) error {
 	switch tok.A {
+	case TokenMagic, TokenCount:
+		return d.skipValue()
 	case TokenListStart:
-		depth := 1
-		for depth > 0 {
+		for {
 			t, err := d.r.Next()
 			if err != nil {
 				return err
 			}
-			if t.A == TokenListStart {
-				depth++
-			} else if t.A == TokenListEnd {
-				depth--
+			if t.A == TokenListEnd {
+				return nil
+			}
+			if err := d.skipValueFrom(t); err != nil {
+				return err
 			}
 		}
 	case TokenDictStart:
-		depth := 1
-		for depth > 0 {
-			t, err := d.r.Next()
+		var intKeyType byte
+		for first := true; ; first = false {
+			var key Token
+			var err error
+			if intKeyType != 0 {
+				key, err = d.r.NextIntKey(intKeyType)
+			} else {
+				key, err = d.r.Next()
+			}
 			if err != nil {
 				return err
 			}
-			if t.A == TokenDictStart {
-				depth++
-			} else if t.A == TokenDictEnd {
-				depth--
+			if key.A == TokenDictEnd {
+				return nil
+			}
+			if first && key.A == TokenInt {
+				intKeyType = d.r.lastIntKeyType
+			}
+			if err := d.skipValue(); err != nil {
+				return err
 			}
 		}
 	}
@@ -361,6 +255,11 @@ func toInt64(v interface{}) (int64, error) {
 		return n, nil
 	case uint64:
 		return int64(n), nil
+	case *big.Int:
+		if n.IsInt64() {
+			return n.Int64(), nil
+		}
+		return 0, fmt.Errorf("integer %s overflows int64", n)
 	}
 	return 0, fmt.Errorf("cannot convert %T to int64", v)
 }
@@ -373,6 +272,11 @@ func toUint64(v interface{}) (uint64, error) {
 		return uint64(n), nil
 	case uint64:
 		return n, nil
+	case *big.Int:
+		if n.IsUint64() {
+			return n.Uint64(), nil
+		}
+		return 0, fmt.Errorf("integer %s overflows uint64", n)
 	}
 	return 0, fmt.Errorf("cannot convert %T to uint64", v)
 }
@@ -1,7 +1,10 @@
 package muon
 
 import (
+	"bytes"
 	"math"
+	"math/big"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -151,6 +154,30 @@ func TestUnmarshal_Array(t *testing.T) {
 	assert.Equal(t, [3]int32{10, 20, 30}, out)
 }
 
+func TestUnmarshal_ArrayExtraElementsSkipped(t *testing.T) {
+	data := encode(t, []interface{}{"a", []interface{}{"b", "c"}, map[string]int{"d": 1}})
+	var out [1]interface{}
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, [1]interface{}{"a"}, out)
+}
+
+func TestUnmarshal_ReusedAcrossTypes(t *testing.T) {
+	// decoders compiled for one type must not leak into another with the
+	// same shape
+	type a struct {
+		N int `muon:"n"`
+	}
+	type b struct {
+		N string `muon:"n"`
+	}
+	var outA []a
+	require.NoError(t, Unmarshal(encode(t, []a{{1}, {2}}), &outA))
+	assert.Equal(t, []a{{1}, {2}}, outA)
+	var outB map[string]b
+	require.NoError(t, Unmarshal(encode(t, map[string]b{"x": {"y"}}), &outB))
+	assert.Equal(t, map[string]b{"x": {"y"}}, outB)
+}
+
 func TestUnmarshal_Pointer(t *testing.T) {
 	data := encode(t, "pointed")
 	var s string
@@ -232,6 +259,51 @@ func TestUnmarshal_SkipNestedValue(t *testing.T) {
 	assert.Equal(t, "test", out.Name)
 }
 
+func TestUnmarshal_SkipIntKeyedDict(t *testing.T) {
+	// raw keys after the first are not tokens: 146 is 0x92, a dict start
+	skipped := Dict{{Key: uint8(0), Value: 1}, {Key: uint8(146), Value: []int{2}}}
+
+	t.Run("unknown field", func(t *testing.T) {
+		var out struct {
+			Name string `muon:"name"`
+		}
+		data := encode(t, Dict{{Key: "x", Value: skipped}, {Key: "name", Value: "n"}})
+		require.NoError(t, Unmarshal(data, &out))
+		assert.Equal(t, "n", out.Name)
+	})
+
+	t.Run("extra array element", func(t *testing.T) {
+		var out [1]string
+		require.NoError(t, Unmarshal(encode(t, []interface{}{"a", skipped, skipped}), &out))
+		assert.Equal(t, [1]string{"a"}, out)
+	})
+
+	t.Run("extra positional value", func(t *testing.T) {
+		var out struct {
+			_ struct{} `muon:",toarray"`
+			A string
+		}
+		require.NoError(t, Unmarshal(encode(t, []interface{}{"a", skipped}), &out))
+		assert.Equal(t, "a", out.A)
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		var out []sample
+		data := encode(t, Dict{{Key: "x", Value: skipped}, {Key: "t", Value: []int64{7}}})
+		require.NoError(t, Unmarshal(data, &out))
+		assert.Equal(t, []sample{{T: 7}}, out)
+	})
+
+	t.Run("count tag", func(t *testing.T) {
+		var out struct {
+			Name string `muon:"name"`
+		}
+		data := []byte{dictStart, 'x', 0, tagCount, 0x01, listStart, 0xA1, listEnd, 'n', 'a', 'm', 'e', 0, 'n', 0, dictEnd}
+		require.NoError(t, Unmarshal(data, &out))
+		assert.Equal(t, "n", out.Name)
+	})
+}
+
 func TestUnmarshal_EmptySlice(t *testing.T) {
 	var out []string
 	require.NoError(t, Unmarshal(encode(t, []interface{}{}), &out))
@@ -274,3 +346,223 @@ func TestUnmarshal_RoundTrip(t *testing.T) {
 	assert.InDelta(t, in.Score, out.Score, 1e-10)
 	assert.Equal(t, in.Active, out.Active)
 }
+
+func TestUnmarshal_TextUnmarshaler(t *testing.T) {
+	t.Run("net_ip", func(t *testing.T) {
+		var out net.IP
+		require.NoError(t, Unmarshal(encode(t, net.IPv4(10, 0, 0, 1)), &out))
+		assert.Equal(t, "10.0.0.1", out.String())
+	})
+	t.Run("big_int_field", func(t *testing.T) {
+		type S struct {
+			N big.Int `muon:"n"`
+		}
+		in := S{}
+		in.N.SetString("123456789012345678901234567890", 10)
+		var out S
+		require.NoError(t, Unmarshal(encode(t, &in), &out))
+		assert.Equal(t, 0, in.N.Cmp(&out.N))
+	})
+	t.Run("map_keys", func(t *testing.T) {
+		in := map[upperKey]string{{'a', 'b', 'c'}: "local"}
+		var out map[upperKey]string
+		require.NoError(t, Unmarshal(encode(t, in), &out))
+		assert.Equal(t, in, out)
+	})
+	t.Run("int_still_accepted", func(t *testing.T) {
+		// a TextUnmarshaler target falls back to its kind for other tokens
+		var out textLevel
+		require.NoError(t, Unmarshal(encode(t, 2), &out))
+		assert.Equal(t, textLevel(2), out)
+	})
+}
+
+type textLevel int
+
+func (l *textLevel) UnmarshalText(text []byte) error {
+	*l = textLevel(len(text))
+	return nil
+}
+
+func TestUnmarshal_BinaryUnmarshaler(t *testing.T) {
+	var out blob
+	require.NoError(t, Unmarshal(encode(t, blob{data: []byte{1, 2, 3}}), &out))
+	assert.Equal(t, []byte{1, 2, 3}, out.data)
+
+	require.NoError(t, Unmarshal(encode(t, "ab"), &out))
+	assert.Equal(t, []byte("ab"), out.data)
+
+	err := Unmarshal(encode(t, []int16{1}), &out)
+	me, ok := err.(MuonError)
+	require.True(t, ok)
+	assert.Equal(t, ErrCodeTypeMismatch, me.Code)
+}
+
+func BenchmarkUnmarshal_StructSlice(b *testing.B) {
+	var buf bytes.Buffer
+	if err := (&Encoder{}).Write(&buf, benchRecords(10000)); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var out []benchRecord
+		if err := Unmarshal(data, &out); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func requireMuonError(t *testing.T, err error, code int) MuonError {
+	t.Helper()
+	me, ok := err.(MuonError)
+	require.True(t, ok, "want MuonError, got %v", err)
+	assert.Equal(t, code, me.Code)
+	return me
+}
+
+func TestDecoder_DisallowUnknownFields(t *testing.T) {
+	type Partial struct {
+		Name string `muon:"name"`
+	}
+	data := encodeWith(t, &Encoder{Deterministic: true}, map[string]string{"name": "a", "extra": "b"})
+
+	var out Partial
+	require.NoError(t, NewDecoder(data).Unmarshal(&out))
+
+	d := NewDecoder(data)
+	d.DisallowUnknownFields = true
+	me := requireMuonError(t, d.Unmarshal(&out), ErrCodeUnknownField)
+	assert.Contains(t, me.Msg, `"extra"`)
+
+	// maps and interfaces take any key
+	var m map[string]string
+	d = NewDecoder(data)
+	d.DisallowUnknownFields = true
+	require.NoError(t, d.Unmarshal(&m))
+}
+
+func TestDecoder_StrictArrayLength(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"list":        encode(t, []interface{}{1, 2, 3}),
+		"typed_array": encode(t, []int32{1, 2, 3}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var exact [3]int
+			d := NewDecoder(data)
+			d.StrictArrayLength = true
+			require.NoError(t, d.Unmarshal(&exact))
+			assert.Equal(t, [3]int{1, 2, 3}, exact)
+
+			var short [2]int
+			d = NewDecoder(data)
+			d.StrictArrayLength = true
+			me := requireMuonError(t, d.Unmarshal(&short), ErrCodeArrayLength)
+			assert.Contains(t, me.Msg, "index 2")
+
+			var long [4]int
+			d = NewDecoder(data)
+			d.StrictArrayLength = true
+			me = requireMuonError(t, d.Unmarshal(&long), ErrCodeArrayLength)
+			assert.Contains(t, me.Msg, "got 3")
+
+			require.NoError(t, Unmarshal(data, &short))
+			assert.Equal(t, [2]int{1, 2}, short)
+		})
+	}
+}
+
+func TestDecoder_RejectTrailingData(t *testing.T) {
+	data := append(encode(t, "a"), encode(t, "b")...)
+
+	var s string
+	d := NewDecoder(data)
+	require.NoError(t, d.Unmarshal(&s))
+	require.NoError(t, d.Unmarshal(&s))
+	assert.Equal(t, "b", s)
+
+	d = NewDecoder(data)
+	d.RejectTrailingData = true
+	me := requireMuonError(t, d.Unmarshal(&s), ErrCodeTrailingData)
+	assert.Equal(t, "2 bytes of trailing data at offset 2", me.Msg)
+
+	// padding is not data
+	d = NewDecoder(append(encode(t, "a"), tagPadding, tagPadding))
+	d.RejectTrailingData = true
+	require.NoError(t, d.Unmarshal(&s))
+}
+
+func TestDecoder_RejectDuplicateKeys(t *testing.T) {
+	dup := []byte{dictStart, 'a', 0, 0xA1, 'b', 0, 0xA2, 'a', 0, 0xA3, dictEnd}
+	intDup := []byte{dictStart, 0xB0, 1, 0xA1, 1, 0xA2, dictEnd}
+
+	var m map[string]int
+	require.NoError(t, Unmarshal(dup, &m))
+	assert.Equal(t, map[string]int{"a": 3, "b": 2}, m)
+
+	targets := map[string]struct {
+		data   []byte
+		target interface{}
+	}{
+		"struct":     {dup, &struct{ A, B int }{}},
+		"map":        {dup, &map[string]int{}},
+		"interface":  {dup, new(interface{})},
+		"int_map":    {intDup, &map[int8]int{}},
+		"int_iface":  {intDup, new(interface{})},
+		"unknown_in": {dup, &struct{ B int }{}},
+	}
+	for name, tc := range targets {
+		t.Run(name, func(t *testing.T) {
+			d := NewDecoder(tc.data)
+			d.RejectDuplicateKeys = true
+			me := requireMuonError(t, d.Unmarshal(tc.target), ErrCodeDuplicateKey)
+			assert.Contains(t, me.Msg, "duplicate key")
+		})
+	}
+
+	d := NewDecoder(dup)
+	d.RejectDuplicateKeys = true
+	_, err := d.Decode()
+	requireMuonError(t, err, ErrCodeDuplicateKey)
+}
+
+func TestUnmarshal_TypedArrayIntoPlatformInts(t *testing.T) {
+	for _, in := range []interface{}{[]int8{-1, 2}, []int16{-1, 2}, []int32{-1, 2}, []int64{-1, 2}} {
+		var out []int
+		require.NoError(t, Unmarshal(encode(t, in), &out))
+		assert.Equal(t, []int{-1, 2}, out)
+	}
+	for _, in := range []interface{}{[]uint8{1, 2}, []uint16{1, 2}, []uint32{1, 2}, []uint64{1, 2}, []int64{1, 2}} {
+		var out []uint
+		require.NoError(t, Unmarshal(encode(t, in), &out))
+		assert.Equal(t, []uint{1, 2}, out)
+	}
+
+	var arr [2]int
+	require.NoError(t, Unmarshal(encode(t, []uint16{7, 8}), &arr))
+	assert.Equal(t, [2]int{7, 8}, arr)
+
+	t.Run("range checks", func(t *testing.T) {
+		var u []uint
+		requireMuonError(t, Unmarshal(encode(t, []int8{1, -1}), &u), ErrCodeOverflow)
+		var i8 []int8
+		requireMuonError(t, Unmarshal(encode(t, []int16{200}), &i8), ErrCodeOverflow)
+		var u8 [1]uint8
+		requireMuonError(t, Unmarshal(encode(t, []uint32{256}), &u8), ErrCodeOverflow)
+		var i []int64
+		requireMuonError(t, Unmarshal(encode(t, []uint64{math.MaxUint64}), &i), ErrCodeOverflow)
+		var s []string
+		requireMuonError(t, Unmarshal(encode(t, []int32{65}), &s), ErrCodeTypeMismatch)
+	})
+
+	t.Run("floats into integers", func(t *testing.T) {
+		// no silent truncation, as for scalar floats
+		var i []int
+		requireMuonError(t, Unmarshal(encode(t, []float64{1.5}), &i), ErrCodeTypeMismatch)
+		var i32 [1]int32
+		requireMuonError(t, Unmarshal(encode(t, []float32{2}), &i32), ErrCodeTypeMismatch)
+		var u []uint16
+		requireMuonError(t, Unmarshal(encode(t, []Float16{NewFloat16(1)}), &u), ErrCodeTypeMismatch)
+	})
+}
@@ -0,0 +1,161 @@
+package muon
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+
+	"ekyu.moe/leb128"
+)
+
+// wireTypes maps the names accepted by the type= tag option to the type
+// byte they select.
+var wireTypes = map[string]byte{
+	"i8":  typeInt8,
+	"i16": typeInt16,
+	"i32": typeInt32,
+	"i64": typeInt64,
+	"u8":  typeUint8,
+	"u16": typeUint16,
+	"u32": typeUint32,
+	"u64": typeUint64,
+	"f16": typeFloat16,
+	"f32": typeFloat32,
+	"f64": typeFloat64,
+	"leb": 0xBB,
+}
+
+// wireTypeFits reports whether values of kind k can be written as typeByte:
+// integers as fixed-size integers or SLEB128, floats as floats.
+func wireTypeFits(k reflect.Kind, typeByte byte) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return typeByte >= typeInt8 && typeByte <= typeUint64 || typeByte == 0xBB
+	case reflect.Float32, reflect.Float64:
+		return typeByte == typeFloat16 || typeByte == typeFloat32 || typeByte == typeFloat64
+	}
+	return false
+}
+
+// newWireTypeEncoder returns the encoder for a struct field of type t tagged
+// type=name. Numbers are written with that type byte; slices and arrays of
+// numbers as a TypedArray of it, or as a list of such numbers for leb and
+// under DisableTypedArrays. Integers out of range are an error.
+func newWireTypeEncoder(t reflect.Type, name string) encoderFunc {
+	base := t
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	typeByte, ok := wireTypes[name]
+	var enc encoderFunc
+	switch {
+	case !ok:
+		enc = func(e *Encoder, w io.Writer, v reflect.Value) error {
+			return fmt.Errorf("unknown wire type %q", name)
+		}
+	case wireTypeFits(base.Kind(), typeByte):
+		enc = func(e *Encoder, w io.Writer, v reflect.Value) error {
+			return e.writeWireTyped(w, v, typeByte)
+		}
+	case (base.Kind() == reflect.Slice || base.Kind() == reflect.Array) && wireTypeFits(base.Elem().Kind(), typeByte):
+		enc = func(e *Encoder, w io.Writer, v reflect.Value) error {
+			return e.writeWireTypedList(w, v, typeByte)
+		}
+	default:
+		enc = func(e *Encoder, w io.Writer, v reflect.Value) error {
+			return fmt.Errorf("wire type %s does not apply to %s", name, base)
+		}
+	}
+	return wrapPtrEncoder(t, enc)
+}
+
+// writeWireTyped writes the number v with type byte typeByte.
+func (e *Encoder) writeWireTyped(w io.Writer, v reflect.Value, typeByte byte) error {
+	b := append(e.scratch[:0], typeByte)
+	switch typeByte {
+	case 0xBB:
+		if k := v.Kind(); k >= reflect.Uint && k <= reflect.Uint64 {
+			if u := v.Uint(); u > math.MaxInt64 {
+				return e.flushScratch(w, appendBigSleb128(b, new(big.Int).SetUint64(u)))
+			}
+			return e.flushScratch(w, leb128.AppendSleb128(b, int64(v.Uint())))
+		}
+		return e.flushScratch(w, leb128.AppendSleb128(b, v.Int()))
+	case typeFloat16:
+		return e.flushScratch(w, appendUint16(b, float64ToFloat16(v.Float())))
+	}
+	b, err := appendWireTypedElem(b, v, typeByte)
+	if err != nil {
+		return err
+	}
+	return e.flushScratch(w, b)
+}
+
+// writeWireTypedList writes rv, a slice or array of numbers, as a
+// TypedArray of typeByte.
+func (e *Encoder) writeWireTypedList(w io.Writer, rv reflect.Value, typeByte byte) error {
+	n := rv.Len()
+	if typeByte == 0xBB || e.DisableTypedArrays {
+		if err := e.writeByte(w, listStart); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := e.writeWireTyped(w, rv.Index(i), typeByte); err != nil {
+				return err
+			}
+		}
+		return e.writeByte(w, listEnd)
+	}
+
+	b := append(e.scratch[:0], typedArray, typeByte)
+	b = leb128.AppendUleb128(b, uint64(n))
+	for i := 0; i < n; i++ {
+		var err error
+		if b, err = appendWireTypedElem(b, rv.Index(i), typeByte); err != nil {
+			return err
+		}
+		if len(b) >= typedArrayFlushSize {
+			if err := e.flushScratch(w, b); err != nil {
+				return err
+			}
+			b = e.scratch[:0]
+		}
+	}
+	return e.flushScratch(w, b)
+}
+
+// appendWireTypedElem appends the number v as a TypedArray element of
+// typeByte. Integers are converted to the element type first.
+func appendWireTypedElem(b []byte, v reflect.Value, typeByte byte) ([]byte, error) {
+	if typeByte < typeInt8 || typeByte > typeUint64 {
+		return appendTypedElem(b, v, typeByte)
+	}
+	et := typedArrayElemTypes[typeByte]
+	if !intFits(v, et) {
+		return b, fmt.Errorf("integer %v overflows %s", v, et)
+	}
+	return appendTypedElem(b, v.Convert(et), typeByte)
+}
+
+// intFits reports whether the integer v can be converted to the integer
+// type t without loss.
+func intFits(v reflect.Value, t reflect.Type) bool {
+	zero := reflect.Zero(t)
+	signed := t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x := v.Int()
+		if signed {
+			return !zero.OverflowInt(x)
+		}
+		return x >= 0 && !zero.OverflowUint(uint64(x))
+	}
+	u := v.Uint()
+	if signed {
+		return u <= math.MaxInt64 && !zero.OverflowInt(int64(u))
+	}
+	return !zero.OverflowUint(u)
+}
@@ -0,0 +1,99 @@
+package muon
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type reading struct {
+	Samples []float64 `muon:"samples,type=f32"`
+	Level   int       `muon:"level,type=i16"`
+	Count   uint64    `muon:"count,type=leb"`
+	Gain    *float64  `muon:"gain,type=f16"`
+	Codes   [2]int32  `muon:"codes,type=u8"`
+}
+
+func TestWireType(t *testing.T) {
+	gain := 1.5
+	in := reading{Samples: []float64{0.5, 2}, Level: -2, Count: 3, Gain: &gain, Codes: [2]int32{7, 200}}
+	data := encode(t, in)
+	assert.Equal(t, []Token{
+		{A: TokenDictStart},
+		{A: TokenString, Data: "samples"}, {A: TokenTypedArray, Data: []float32{0.5, 2}},
+		{A: TokenString, Data: "level"}, {A: TokenInt, Data: -2},
+		{A: TokenString, Data: "count"}, {A: TokenInt, Data: 3},
+		{A: TokenString, Data: "gain"}, {A: TokenFloat, Data: 1.5},
+		{A: TokenString, Data: "codes"}, {A: TokenTypedArray, Data: []uint8{7, 200}},
+		{A: TokenDictEnd},
+	}, tokens(t, data))
+	assert.Contains(t, string(data), "level\x00\xB1\xFE\xFF")
+	assert.Contains(t, string(data), "count\x00\xBB\x03")
+	assert.Contains(t, string(data), "gain\x00\xB8\x00\x3E")
+
+	var out reading
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+
+	t.Run("nil pointer", func(t *testing.T) {
+		var out reading
+		require.NoError(t, Unmarshal(encode(t, reading{}), &out))
+		assert.Nil(t, out.Gain)
+	})
+}
+
+func TestWireType_Lists(t *testing.T) {
+	type lists struct {
+		Big []uint64  `muon:"big,type=leb"`
+		F16 []float32 `muon:"f16,type=f16"`
+		I8  []int     `muon:"i8,type=i8"`
+	}
+	in := lists{Big: []uint64{1, math.MaxUint64}, F16: []float32{0.25, -2}, I8: []int{-1, 5}}
+
+	data := encode(t, in)
+	assert.Equal(t, []Token{
+		{A: TokenDictStart},
+		{A: TokenString, Data: "big"}, {A: TokenListStart}, {A: TokenInt, Data: 1}, {A: TokenInt, Data: uint64(math.MaxUint64)}, {A: TokenListEnd},
+		{A: TokenString, Data: "f16"}, {A: TokenTypedArray, Data: []Float16{0x3400, 0xC000}},
+		{A: TokenString, Data: "i8"}, {A: TokenTypedArray, Data: []int8{-1, 5}},
+		{A: TokenDictEnd},
+	}, tokens(t, data))
+	var out lists
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+
+	data = encodeWith(t, &Encoder{DisableTypedArrays: true}, in)
+	assert.Contains(t, string(data), "i8\x00\x90\xB0\xFF\xB0\x05\x91")
+	out = lists{}
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+}
+
+func TestWireType_Errors(t *testing.T) {
+	var buf bytes.Buffer
+	for _, v := range []interface{}{
+		struct {
+			V int `muon:"v,type=i8"`
+		}{V: 128},
+		struct {
+			V []uint32 `muon:"v,type=i16"`
+		}{V: []uint32{1, 1 << 16}},
+		struct {
+			V int `muon:"v,type=u8"`
+		}{V: -1},
+		struct {
+			V float64 `muon:"v,type=i32"`
+		}{},
+		struct {
+			V string `muon:"v,type=f32"`
+		}{},
+		struct {
+			V int `muon:"v,type=int"`
+		}{},
+	} {
+		assert.Error(t, (&Encoder{}).Write(&buf, v), "%#v", v)
+	}
+}
@@ -1,16 +1,19 @@
 package muon
 
 import (
+	"bytes"
+	"encoding"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"math"
+	"math/big"
 	"reflect"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"ekyu.moe/leb128"
-	"github.com/oherych/muon/internal"
 )
 
 const (
@@ -19,7 +22,7 @@ const (
 )
 
 var (
-	// maps element kind → TypedArray type byte; int/uint omitted (platform-dependent)
+	// maps element kind → TypedArray type byte; int/uint omitted (platform-dependent, see IntSliceFormat)
 	elemKindToTypeByte = map[reflect.Kind]byte{
 		reflect.Int8:    typeInt8,
 		reflect.Int16:   typeInt16,
@@ -34,6 +37,35 @@ var (
 	}
 )
 
+// IntSliceFormat selects how slices and arrays of the platform-dependent int
+// and uint types are written.
+type IntSliceFormat int
+
+const (
+	// IntSliceList writes a list of SLEB128 integers.
+	IntSliceList IntSliceFormat = iota
+	// IntSlice64 writes an int64 or uint64 TypedArray.
+	IntSlice64
+	// IntSliceSmallest writes a TypedArray of the smallest signed (for int)
+	// or unsigned (for uint) element type that holds every value.
+	IntSliceSmallest
+)
+
+// typedArrayElemTypes maps TypedArray type bytes to Go element types.
+var typedArrayElemTypes = map[byte]reflect.Type{
+	typeInt8:    reflect.TypeOf(int8(0)),
+	typeInt16:   reflect.TypeOf(int16(0)),
+	typeInt32:   reflect.TypeOf(int32(0)),
+	typeInt64:   reflect.TypeOf(int64(0)),
+	typeUint8:   reflect.TypeOf(uint8(0)),
+	typeUint16:  reflect.TypeOf(uint16(0)),
+	typeUint32:  reflect.TypeOf(uint32(0)),
+	typeUint64:  reflect.TypeOf(uint64(0)),
+	typeFloat16: float16Type,
+	typeFloat32: reflect.TypeOf(float32(0)),
+	typeFloat64: reflect.TypeOf(float64(0)),
+}
+
 // Encoder serializes Go values to the muon binary format.
 //
 // The zero value is ready to use. Fields may be set before the first call:
@@ -52,14 +84,55 @@ type Encoder struct {
 	// (strings alphabetically, integers numerically) and LRU is disabled.
 	// The same input always produces identical bytes.
 	Deterministic bool
-	lru           []string
+	// StrictMarshaler validates the output of [Marshaler] and
+	// [MarshalerStream] implementations: it must be exactly one complete muon
+	// value, and any string references in it must resolve against the
+	// encoder's LRU table. Strings it adds to the table are recorded so later
+	// back-references stay in sync. MarshalerStream output is buffered while
+	// it is checked.
+	StrictMarshaler bool
+	// TimeFormat selects the representation of time.Time and time.Duration
+	// values. Struct fields can override it with the rfc3339, unixnano or
+	// unixms tag options.
+	TimeFormat TimeFormat
+	// Naming derives the dict key of struct fields without a name in their
+	// muon tag; the default lower-cases the Go field name. See [Naming].
+	Naming Naming
+	// Columnar writes slices of flat structs (fields of bool, number and
+	// string types only) as a dict mapping each field name to a column of
+	// values: a TypedArray for numbers, a list otherwise. A single field can
+	// opt in with the columnar tag option instead. [Unmarshal] reads both
+	// forms.
+	Columnar bool
+	// IntSlices selects how []int and []uint are written; the default is a
+	// list. See [IntSliceFormat].
+	IntSlices IntSliceFormat
+	// DetectTypedArrays writes slices and arrays of interface{} that hold
+	// only integers or only floats as a TypedArray of the narrowest element
+	// type that keeps every value exact, instead of as a list.
+	DetectTypedArrays bool
+	// DisableTypedArrays writes plain lists wherever a TypedArray would be
+	// written, for readers that do not support them. It overrides
+	// DetectTypedArrays and IntSlices; [Encoder.WriteChunkedTypedArray] and
+	// [Encoder.WriteBlobFrom] are not affected.
+	DisableTypedArrays bool
+	// RejectInvalidUTF8 makes strings that are not valid UTF-8 an error.
+	// Without it they are written in the size-tagged form, which keeps
+	// arbitrary bytes intact.
+	RejectInvalidUTF8 bool
+
+	lru     []string
+	scratch []byte // reused for assembling small writes
 }
 
 // Write encodes in and writes the muon bytes to w.
 // Supported types: nil, bool, int/uint (all sizes), float32/64, string,
 // slice, array, map (string or integer keys), struct, and pointer.
-// Types implementing [Marshaler] or [MarshalerStream] are encoded via those
-// interfaces. Returns an error for unsupported types or write failures.
+// Types implementing [MarshalerTo], [Marshaler] or [MarshalerStream] are
+// encoded via those interfaces, in that order of precedence. Otherwise
+// [encoding.TextMarshaler] values are written as strings and
+// [encoding.BinaryMarshaler] values as uint8 TypedArrays.
+// Returns an error for unsupported types or write failures.
 func (e *Encoder) Write(w io.Writer, in interface{}) error {
 	return e.write(w, in)
 }
@@ -116,57 +189,100 @@ func (e *Encoder) WriteChunkedTypedArray(w io.Writer, typeByte byte, chunks ...i
 }
 
 func (e *Encoder) write(w io.Writer, in interface{}) error {
+	return e.writeValue(w, reflect.ValueOf(in))
+}
+
+func (e *Encoder) writeValue(w io.Writer, rv reflect.Value) error {
+	if !rv.IsValid() {
+		return e.writeByte(w, nilValue)
+	}
+	return typeEncoder(rv.Type(), e.Naming)(e, w, rv)
+}
+
+var (
+	marshalerToType     = reflect.TypeOf((*MarshalerTo)(nil)).Elem()
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	marshalerStreamType = reflect.TypeOf((*MarshalerStream)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+func isMarshaler(t reflect.Type) bool {
+	return t.Implements(marshalerToType) || t.Implements(marshalerType) || t.Implements(marshalerStreamType) ||
+		t.Implements(textMarshalerType) || t.Implements(binaryMarshalerType)
+}
+
+func (e *Encoder) writeMarshaler(w io.Writer, in interface{}) error {
+	if m, ok := in.(MarshalerTo); ok {
+		return m.MarshalMuonTo(e, w)
+	}
+
 	if m, ok := in.(Marshaler); ok {
 		data, err := m.MarshalMuon()
 		if err != nil {
 			return err
 		}
+		if e.StrictMarshaler {
+			if err := e.checkMarshaled(data); err != nil {
+				return fmt.Errorf("%T.MarshalMuon: %w", in, err)
+			}
+		}
 		return e.writeBytes(w, data)
 	}
 
 	if m, ok := in.(MarshalerStream); ok {
-		return m.MarshalMuon(w)
+		return e.writeMarshalerStream(w, m)
 	}
 
-	if in == nil {
-		return e.writeByte(w, nilValue)
+	if m, ok := in.(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return err
+		}
+		return e.writeString(w, string(text))
 	}
 
-	rv := reflect.ValueOf(in)
-	kind := rv.Kind()
-
-	if kind == reflect.Bool {
-		return e.writeBool(w, rv.Bool())
-	}
-	if kind == reflect.String {
-		return e.writeString(w, rv.String())
-	}
-	if kind >= reflect.Int && kind <= reflect.Int64 {
-		return e.writeInteger(w, rv)
+	data, err := in.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
 	}
-	if kind >= reflect.Uint && kind <= reflect.Uint64 {
-		return e.writeUint(w, rv)
+	return e.writeTypedArray(w, reflect.ValueOf(data), typeUint8)
+}
+
+func (e *Encoder) writeMarshalerStream(w io.Writer, m MarshalerStream) error {
+	if !e.StrictMarshaler {
+		return m.MarshalMuon(w)
 	}
-	if kind == reflect.Float32 || kind == reflect.Float64 {
-		return e.writeFloat(w, rv)
+	var buf bytes.Buffer
+	if err := m.MarshalMuon(&buf); err != nil {
+		return err
 	}
-	if kind == reflect.Slice || kind == reflect.Array {
-		return e.writeList(w, rv)
+	if err := e.checkMarshaled(buf.Bytes()); err != nil {
+		return fmt.Errorf("%T.MarshalMuon: %w", m, err)
 	}
-	if kind == reflect.Map {
-		return e.writeMap(w, rv)
+	return e.writeBytes(w, buf.Bytes())
+}
+
+// checkMarshaled verifies that data holds exactly one complete muon value.
+// The value is decoded against a copy of the encoder's LRU table, which then
+// replaces the original so that strings introduced by data can be referenced
+// later in the stream.
+func (e *Encoder) checkMarshaled(data []byte) error {
+	d := Decoder{r: Reader{in: data, lru: append([]string(nil), e.lru...)}}
+	if _, err := d.Decode(); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("output is not a complete value: %w", err)
 	}
-	if kind == reflect.Struct {
-		return e.writeStruct(w, rv)
+	d.r.skipPadding()
+	if d.r.scanp != len(data) {
+		return fmt.Errorf("output has %d bytes after the first value", len(data)-d.r.scanp)
 	}
-	if kind == reflect.Ptr {
-		if rv.IsNil() {
-			return e.writeByte(w, nilValue)
-		}
-		return e.write(w, rv.Elem().Interface())
+	if e.LRU && !e.Deterministic {
+		e.lru = d.r.lru
 	}
-
-	return fmt.Errorf("type %s not supportable", rv.Type())
+	return nil
 }
 
 func (e *Encoder) writeBool(w io.Writer, v bool) error {
@@ -176,24 +292,26 @@ func (e *Encoder) writeBool(w io.Writer, v bool) error {
 	return e.writeByte(w, boolFalse)
 }
 
-func (e *Encoder) writeInteger(w io.Writer, rv reflect.Value) error {
-	v := rv.Int()
+func (e *Encoder) writeInt64(w io.Writer, v int64) error {
 	if v >= 0 && v <= 9 {
-		return e.writeBytes(w, []byte{0xA0 + byte(v)})
+		return e.writeByte(w, 0xA0+byte(v))
 	}
-	return e.writeBytes(w, []byte{0xBB}, leb128.AppendSleb128(nil, v))
+	b := append(e.scratch[:0], 0xBB)
+	return e.flushScratch(w, leb128.AppendSleb128(b, v))
 }
 
-func (e *Encoder) writeUint(w io.Writer, rv reflect.Value) error {
-	v := rv.Uint()
+func (e *Encoder) writeUint64(w io.Writer, v uint64) error {
 	if v <= 9 {
-		return e.writeBytes(w, []byte{0xA0 + byte(v)})
+		return e.writeByte(w, 0xA0+byte(v))
 	}
-	return e.writeBytes(w, []byte{0xBB}, leb128.AppendSleb128(nil, int64(v)))
+	b := append(e.scratch[:0], 0xBB)
+	if v > math.MaxInt64 {
+		return e.flushScratch(w, appendBigSleb128(b, new(big.Int).SetUint64(v)))
+	}
+	return e.flushScratch(w, leb128.AppendSleb128(b, int64(v)))
 }
 
-func (e *Encoder) writeFloat(w io.Writer, rv reflect.Value) error {
-	v := rv.Float()
+func (e *Encoder) writeFloat(w io.Writer, v float64) error {
 	if math.IsNaN(v) {
 		return e.writeByte(w, nanValue)
 	}
@@ -203,17 +321,21 @@ func (e *Encoder) writeFloat(w io.Writer, rv reflect.Value) error {
 	if math.IsInf(v, 1) {
 		return e.writeByte(w, positiveInfValue)
 	}
-	var buf [9]byte
-	buf[0] = floatF64
-	binary.LittleEndian.PutUint64(buf[1:], math.Float64bits(v))
-	return e.writeBytes(w, buf[:])
+	b := append(e.scratch[:0], floatF64, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(b[1:], math.Float64bits(v))
+	return e.flushScratch(w, b)
 }
 
 func (e *Encoder) writeString(w io.Writer, v string) error {
+	valid := utf8.ValidString(v)
+	if !valid && e.RejectInvalidUTF8 {
+		return fmt.Errorf("string %q is not valid UTF-8", v)
+	}
 	if e.LRU && !e.Deterministic {
 		for i, s := range e.lru {
 			if s == v {
-				return e.writeBytes(w, []byte{stringRef}, leb128.AppendUleb128(nil, uint64(i)))
+				b := append(e.scratch[:0], stringRef)
+				return e.flushScratch(w, leb128.AppendUleb128(b, uint64(i)))
 			}
 		}
 		// not in LRU — write with 0x8C tag and remember
@@ -223,11 +345,15 @@ func (e *Encoder) writeString(w io.Writer, v string) error {
 		}
 	}
 
-	// fixed-length string: length >= 512 bytes or contains 0x00
-	if len(v) >= longStringFactor || strings.ContainsRune(v, stringEnd) {
-		return e.writeBytes(w, []byte{tagSize}, leb128.AppendUleb128(nil, uint64(len(v))), []byte(v))
+	// fixed-length string: length >= 512 bytes, contains 0x00, or is not
+	// valid UTF-8 and so may start with a byte the reader takes for a tag
+	if len(v) >= longStringFactor || strings.ContainsRune(v, stringEnd) || !valid {
+		b := append(e.scratch[:0], tagSize)
+		b = leb128.AppendUleb128(b, uint64(len(v)))
+		return e.flushScratch(w, append(b, v...))
 	}
-	return e.writeBytes(w, []byte(v), []byte{stringEnd})
+	b := append(e.scratch[:0], v...)
+	return e.flushScratch(w, append(b, stringEnd))
 }
 
 func (e *Encoder) lruPrepend(s string) {
@@ -237,77 +363,265 @@ func (e *Encoder) lruPrepend(s string) {
 	e.lru = append([]string{s}, e.lru...)
 }
 
-func (e *Encoder) writeList(w io.Writer, rv reflect.Value) error {
-	elemKind := rv.Type().Elem().Kind()
-	if tb, ok := elemKindToTypeByte[elemKind]; ok {
-		return e.writeTypedArray(w, rv, tb)
+// typedArrayFlushSize bounds the bytes buffered while writing a TypedArray.
+const typedArrayFlushSize = 4096
+
+func (e *Encoder) writeTypedArray(w io.Writer, rv reflect.Value, typeByte byte) error {
+	if e.DisableTypedArrays {
+		return e.writeScalarList(w, rv)
+	}
+	n := rv.Len()
+	b := append(e.scratch[:0], typedArray, typeByte)
+	b = leb128.AppendUleb128(b, uint64(n))
+	if typeByte == typeUint8 && rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+		if err := e.flushScratch(w, b); err != nil {
+			return err
+		}
+		return e.writeBytes(w, rv.Bytes())
+	}
+	for i := 0; i < n; i++ {
+		var err error
+		if b, err = appendTypedElem(b, rv.Index(i), typeByte); err != nil {
+			return err
+		}
+		if len(b) >= typedArrayFlushSize {
+			if err := e.flushScratch(w, b); err != nil {
+				return err
+			}
+			b = e.scratch[:0]
+		}
+	}
+	return e.flushScratch(w, b)
+}
+
+// detectTypedArray returns the elements of rv, a slice or array of
+// interface values, converted to the narrowest numeric type that holds them
+// all without loss, along with its TypedArray type byte. It returns false
+// unless rv is non-empty and holds only integers or only floats.
+func detectTypedArray(rv reflect.Value) (reflect.Value, byte, bool) {
+	n := rv.Len()
+	if n == 0 {
+		return reflect.Value{}, 0, false
+	}
+	var (
+		ints, floats bool
+		min          int64
+		max          uint64
+		f32          = true
+	)
+	for i := 0; i < n; i++ {
+		ev := rv.Index(i).Elem()
+		if !ev.IsValid() || ev.Type() == durationType || ev.Type() == float16Type || isMarshaler(ev.Type()) || isMarshaler(reflect.PtrTo(ev.Type())) {
+			return reflect.Value{}, 0, false
+		}
+		switch ev.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			ints = true
+			x := ev.Int()
+			if x < min {
+				min = x
+			}
+			if x > 0 && uint64(x) > max {
+				max = uint64(x)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			ints = true
+			if u := ev.Uint(); u > max {
+				max = u
+			}
+		case reflect.Float32:
+			floats = true
+		case reflect.Float64:
+			floats = true
+			if f := ev.Float(); !math.IsNaN(f) && float64(float32(f)) != f {
+				f32 = false
+			}
+		default:
+			return reflect.Value{}, 0, false
+		}
+	}
+
+	var tb byte
+	switch {
+	case ints && floats:
+		return reflect.Value{}, 0, false
+	case floats && f32:
+		tb = typeFloat32
+	case floats:
+		tb = typeFloat64
+	case min >= math.MinInt8 && max <= math.MaxInt8:
+		tb = typeInt8
+	case min >= 0 && max <= math.MaxUint8:
+		tb = typeUint8
+	case min >= math.MinInt16 && max <= math.MaxInt16:
+		tb = typeInt16
+	case min >= 0 && max <= math.MaxUint16:
+		tb = typeUint16
+	case min >= math.MinInt32 && max <= math.MaxInt32:
+		tb = typeInt32
+	case min >= 0 && max <= math.MaxUint32:
+		tb = typeUint32
+	case max <= math.MaxInt64:
+		tb = typeInt64
+	case min >= 0:
+		tb = typeUint64
+	default:
+		return reflect.Value{}, 0, false
+	}
+
+	et := typedArrayElemTypes[tb]
+	out := reflect.MakeSlice(reflect.SliceOf(et), n, n)
+	for i := 0; i < n; i++ {
+		out.Index(i).Set(rv.Index(i).Elem().Convert(et))
 	}
+	return out, tb, true
+}
+
+// writeScalarList writes rv, a slice or array of bools, numbers or strings,
+// as a plain list.
+func (e *Encoder) writeScalarList(w io.Writer, rv reflect.Value) error {
 	if err := e.writeByte(w, listStart); err != nil {
 		return err
 	}
-	for i := 0; i < rv.Len(); i++ {
-		if err := e.write(w, rv.Index(i).Interface()); err != nil {
+	for i, n := 0, rv.Len(); i < n; i++ {
+		if err := e.writeScalar(w, rv.Index(i)); err != nil {
 			return err
 		}
 	}
 	return e.writeByte(w, listEnd)
 }
 
-func (e *Encoder) writeTypedArray(w io.Writer, rv reflect.Value, typeByte byte) error {
+// writeScalar writes rv, a bool, number or string, ignoring any marshaler
+// methods.
+func (e *Encoder) writeScalar(w io.Writer, rv reflect.Value) error {
+	if rv.Type() == float16Type {
+		return float16Encoder(e, w, rv)
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return e.writeBool(w, rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.writeInt64(w, rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return e.writeUint64(w, rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return e.writeFloat(w, rv.Float())
+	case reflect.String:
+		return e.writeString(w, rv.String())
+	}
+	return fmt.Errorf("type %s not supportable", rv.Type())
+}
+
+// writeIntTypedArray writes rv, a slice or array of int or uint, as a
+// TypedArray under e.IntSlices.
+func (e *Encoder) writeIntTypedArray(w io.Writer, rv reflect.Value) error {
+	signed := rv.Type().Elem().Kind() == reflect.Int
+	if e.IntSlices != IntSliceSmallest {
+		if signed {
+			return e.writeTypedArray(w, rv, typeInt64)
+		}
+		return e.writeTypedArray(w, rv, typeUint64)
+	}
+
 	n := rv.Len()
-	if err := e.writeBytes(w, []byte{typedArray, typeByte}, leb128.AppendUleb128(nil, uint64(n))); err != nil {
-		return err
+	if !signed {
+		var max uint64
+		for i := 0; i < n; i++ {
+			if u := rv.Index(i).Uint(); u > max {
+				max = u
+			}
+		}
+		switch {
+		case max <= math.MaxUint8:
+			return e.writeTypedArray(w, rv, typeUint8)
+		case max <= math.MaxUint16:
+			return e.writeTypedArray(w, rv, typeUint16)
+		case max <= math.MaxUint32:
+			return e.writeTypedArray(w, rv, typeUint32)
+		}
+		return e.writeTypedArray(w, rv, typeUint64)
 	}
+	var min, max int64
 	for i := 0; i < n; i++ {
-		if err := e.writeTypedElem(w, rv.Index(i), typeByte); err != nil {
-			return err
+		v := rv.Index(i).Int()
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
 		}
 	}
-	return nil
+	switch {
+	case min >= math.MinInt8 && max <= math.MaxInt8:
+		return e.writeTypedArray(w, rv, typeInt8)
+	case min >= math.MinInt16 && max <= math.MaxInt16:
+		return e.writeTypedArray(w, rv, typeInt16)
+	case min >= math.MinInt32 && max <= math.MaxInt32:
+		return e.writeTypedArray(w, rv, typeInt32)
+	}
+	return e.writeTypedArray(w, rv, typeInt64)
 }
 
 func (e *Encoder) writeTypedElem(w io.Writer, rv reflect.Value, typeByte byte) error {
-	var buf [8]byte
+	b, err := appendTypedElem(e.scratch[:0], rv, typeByte)
+	if err != nil {
+		return err
+	}
+	return e.flushScratch(w, b)
+}
+
+func appendTypedElem(b []byte, rv reflect.Value, typeByte byte) ([]byte, error) {
 	switch typeByte {
 	case typeInt8:
-		buf[0] = byte(rv.Int())
-		return e.writeBytes(w, buf[:1])
+		return append(b, byte(rv.Int())), nil
 	case typeInt16:
-		binary.LittleEndian.PutUint16(buf[:], uint16(rv.Int()))
-		return e.writeBytes(w, buf[:2])
+		return appendUint16(b, uint16(rv.Int())), nil
 	case typeInt32:
-		binary.LittleEndian.PutUint32(buf[:], uint32(rv.Int()))
-		return e.writeBytes(w, buf[:4])
+		return appendUint32(b, uint32(rv.Int())), nil
 	case typeInt64:
-		binary.LittleEndian.PutUint64(buf[:], uint64(rv.Int()))
-		return e.writeBytes(w, buf[:8])
+		return appendUint64(b, uint64(rv.Int())), nil
 	case typeUint8:
-		buf[0] = byte(rv.Uint())
-		return e.writeBytes(w, buf[:1])
+		return append(b, byte(rv.Uint())), nil
 	case typeUint16:
-		binary.LittleEndian.PutUint16(buf[:], uint16(rv.Uint()))
-		return e.writeBytes(w, buf[:2])
+		return appendUint16(b, uint16(rv.Uint())), nil
 	case typeUint32:
-		binary.LittleEndian.PutUint32(buf[:], uint32(rv.Uint()))
-		return e.writeBytes(w, buf[:4])
+		return appendUint32(b, uint32(rv.Uint())), nil
 	case typeUint64:
-		binary.LittleEndian.PutUint64(buf[:], rv.Uint())
-		return e.writeBytes(w, buf[:8])
+		return appendUint64(b, rv.Uint()), nil
+	case typeFloat16:
+		if k := rv.Kind(); k == reflect.Float32 || k == reflect.Float64 {
+			return appendUint16(b, float64ToFloat16(rv.Float())), nil
+		}
+		return appendUint16(b, uint16(rv.Uint())), nil // Float16 bits
 	case typeFloat32:
-		binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(rv.Float())))
-		return e.writeBytes(w, buf[:4])
+		return appendUint32(b, math.Float32bits(float32(rv.Float()))), nil
 	case typeFloat64:
-		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(rv.Float()))
-		return e.writeBytes(w, buf[:8])
+		return appendUint64(b, math.Float64bits(rv.Float())), nil
 	}
-	return fmt.Errorf("unsupported typed array element type byte: 0x%02X", typeByte)
+	return b, fmt.Errorf("unsupported typed array element type byte: 0x%02X", typeByte)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
 }
 
-func (e *Encoder) writeMap(w io.Writer, rv reflect.Value) error {
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24),
+		byte(v>>32), byte(v>>40), byte(v>>48), byte(v>>56))
+}
+
+func (e *Encoder) writeMap(w io.Writer, rv reflect.Value, elemEnc encoderFunc) error {
 	keys := rv.MapKeys()
 	if len(keys) == 0 {
 		return e.writeBytes(w, []byte{dictStart, dictEnd})
 	}
+	if rv.Type().Key().Kind() == reflect.Interface {
+		return e.writeInterfaceKeyMap(w, rv, keys)
+	}
 
 	firstKind := keys[0].Kind()
 	isString := firstKind == reflect.String
@@ -346,6 +660,26 @@ func (e *Encoder) writeMap(w io.Writer, rv reflect.Value) error {
 		}
 	}
 
+	if isInt {
+		// the one key that cannot follow another goes first
+		sleb := firstKind == reflect.Int || firstKind == reflect.Uint
+		lead := -1
+		for i, k := range keys {
+			if !ambiguousIntKey(appendDictIntKey(e.scratch[:0], k, false), sleb) {
+				continue
+			}
+			if lead >= 0 {
+				return fmt.Errorf("dict key %v cannot be encoded unambiguously", k)
+			}
+			lead = i
+		}
+		if lead > 0 {
+			k := keys[lead]
+			copy(keys[1:lead+1], keys[:lead])
+			keys[0] = k
+		}
+	}
+
 	if err := e.writeByte(w, dictStart); err != nil {
 		return err
 	}
@@ -359,7 +693,64 @@ func (e *Encoder) writeMap(w io.Writer, rv reflect.Value) error {
 				return err
 			}
 		}
-		if err := e.write(w, rv.MapIndex(k).Interface()); err != nil {
+		if err := elemEnc(e, w, rv.MapIndex(k)); err != nil {
+			return err
+		}
+	}
+	return e.writeByte(w, dictEnd)
+}
+
+// writeInterfaceKeyMap writes a map with interface-typed keys, such as the
+// map[interface{}]interface{} returned by [Decoder.Decode] for int-keyed
+// dicts, by the rules of [Dict]: keys are matched by their dynamic type and
+// integer keys that share one fixed-size type keep it. Unlike a Dict, the
+// key that can only be written first is moved there.
+func (e *Encoder) writeInterfaceKeyMap(w io.Writer, rv reflect.Value, keys []reflect.Value) error {
+	d := make(Dict, len(keys))
+	for i, k := range keys {
+		d[i] = DictEntry{Key: k.Interface(), Value: rv.MapIndex(k).Interface()}
+	}
+	if e.Deterministic {
+		if err := sortDict(d); err != nil {
+			return err
+		}
+	}
+	return e.writeDict(w, d, true)
+}
+
+// writeTextKeyMap writes a map whose keys implement [encoding.TextMarshaler]
+// as a string-keyed dict.
+func (e *Encoder) writeTextKeyMap(w io.Writer, rv reflect.Value, elemEnc encoderFunc) error {
+	keys := rv.MapKeys()
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			continue
+		}
+		text, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		names[i] = string(text)
+	}
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	if e.Deterministic {
+		sort.Slice(order, func(i, j int) bool {
+			return names[order[i]] < names[order[j]]
+		})
+	}
+
+	if err := e.writeByte(w, dictStart); err != nil {
+		return err
+	}
+	for _, i := range order {
+		if err := e.writeString(w, names[i]); err != nil {
+			return err
+		}
+		if err := elemEnc(e, w, rv.MapIndex(keys[i])); err != nil {
 			return err
 		}
 	}
@@ -367,6 +758,13 @@ func (e *Encoder) writeMap(w io.Writer, rv reflect.Value) error {
 }
 
 func (e *Encoder) writeDictIntKey(w io.Writer, rv reflect.Value, first bool) error {
+	return e.flushScratch(w, appendDictIntKey(e.scratch[:0], rv, first))
+}
+
+// appendDictIntKey appends the integer dict key rv: fixed-size integers as
+// little-endian bytes of their size, int and uint as SLEB128. Only the
+// first key of a dict carries the type byte.
+func appendDictIntKey(b []byte, rv reflect.Value, first bool) []byte {
 	kind := rv.Kind()
 	isUint := kind >= reflect.Uint && kind <= reflect.Uint64
 
@@ -393,54 +791,33 @@ func (e *Encoder) writeDictIntKey(w io.Writer, rv reflect.Value, first bool) err
 
 	if leSize > 0 {
 		if first {
-			if err := e.writeByte(w, typeByte); err != nil {
-				return err
-			}
+			b = append(b, typeByte)
 		}
-		var buf [8]byte
+		n := len(b)
 		if isUint {
-			binary.LittleEndian.PutUint64(buf[:], rv.Uint())
-		} else {
-			binary.LittleEndian.PutUint64(buf[:], uint64(rv.Int()))
+			return appendUint64(b, rv.Uint())[:n+leSize]
 		}
-		return e.writeBytes(w, buf[:leSize])
+		return appendUint64(b, uint64(rv.Int()))[:n+leSize]
 	}
 
 	// int/uint (platform-dependent): SLEB128, omit 0xBB prefix after first key
 	if first {
-		if err := e.writeByte(w, 0xBB); err != nil {
-			return err
-		}
+		b = append(b, 0xBB)
 	}
 	if isUint {
-		return e.writeBytes(w, leb128.AppendSleb128(nil, int64(rv.Uint())))
+		if u := rv.Uint(); u > math.MaxInt64 {
+			return appendBigSleb128(b, new(big.Int).SetUint64(u))
+		}
+		return leb128.AppendSleb128(b, int64(rv.Uint()))
 	}
-	return e.writeBytes(w, leb128.AppendSleb128(nil, rv.Int()))
+	return leb128.AppendSleb128(b, rv.Int())
 }
 
-func (e *Encoder) writeStruct(w io.Writer, rv reflect.Value) error {
-	if err := e.writeBytes(w, []byte{dictStart}); err != nil {
-		return err
-	}
-	tt := rv.Type()
-	for i := 0; i < tt.NumField(); i++ {
-		tf := tt.Field(i)
-		vf := rv.Field(i)
-		if !tf.IsExported() {
-			continue
-		}
-		info := internal.ParseTags(tf)
-		if info.Skip {
-			continue
-		}
-		if err := e.writeString(w, info.Name); err != nil {
-			return err
-		}
-		if err := e.write(w, vf.Interface()); err != nil {
-			return err
-		}
-	}
-	return e.writeBytes(w, []byte{dictEnd})
+// ambiguousIntKey reports whether b, an integer dict key written without
+// its type byte, reads back as the dict end or, for SLEB128 keys, whose
+// reads skip padding, as padding. Such a key can only be written first.
+func ambiguousIntKey(b []byte, sleb bool) bool {
+	return b[0] == dictEnd || sleb && b[0] == tagPadding
 }
 
 func (e *Encoder) writeBytes(w io.Writer, val ...[]byte) error {
@@ -453,6 +830,17 @@ func (e *Encoder) writeBytes(w io.Writer, val ...[]byte) error {
 }
 
 func (e *Encoder) writeByte(w io.Writer, val byte) error {
-	_, err := w.Write([]byte{val})
+	return e.flushScratch(w, append(e.scratch[:0], val))
+}
+
+// flushScratch writes b, which was assembled in e.scratch, and keeps its
+// backing array for the next write unless it grew unusually large.
+func (e *Encoder) flushScratch(w io.Writer, b []byte) error {
+	if cap(b) <= maxScratchSize {
+		e.scratch = b[:0]
+	}
+	_, err := w.Write(b)
 	return err
 }
+
+const maxScratchSize = 64 << 10
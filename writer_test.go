@@ -2,11 +2,18 @@ package muon
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"math"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type G string
@@ -39,6 +46,11 @@ var (
 			encoded: []byte{tagSize, 0x05, 0x74, 0x65, 0x0, 0x73, 0x74},
 			tokens:  []Token{{A: TokenString, Data: "te" + string([]byte{stringEnd}) + "st"}},
 		},
+		"string_invalid_utf8": {
+			golang:  "\x90ab",
+			encoded: []byte{tagSize, 0x03, 0x90, 0x61, 0x62},
+			tokens:  []Token{{A: TokenString, Data: "\x90ab"}},
+		},
 		"long_string": {
 			golang:  "test Lorem ipsum dolor sit amet, consectetur adipiscing elit. Fusce mi mauris, fringilla a gravida ac, vulputate vitae dui. Proin rhoncus ante vitae purus mollis, id hendrerit tellus tempor. Aliquam ut ex nibh. Aenean quis quam eu purus scelerisque viverra ac consequat justo. Sed lobortis interdum facilisis. Sed euismod est magna, at iaculis nisi mollis a. Maecenas nec diam augue. Phasellus volutpat mattis nisi, eu sagittis enim tempor vitae. Aliquam sit amet ante finibus, bibendum lorem et, porta libero. Sed eu.",
 			tokens:  []Token{{A: TokenString, Data: "test Lorem ipsum dolor sit amet, consectetur adipiscing elit. Fusce mi mauris, fringilla a gravida ac, vulputate vitae dui. Proin rhoncus ante vitae purus mollis, id hendrerit tellus tempor. Aliquam ut ex nibh. Aenean quis quam eu purus scelerisque viverra ac consequat justo. Sed lobortis interdum facilisis. Sed euismod est magna, at iaculis nisi mollis a. Maecenas nec diam augue. Phasellus volutpat mattis nisi, eu sagittis enim tempor vitae. Aliquam sit amet ante finibus, bibendum lorem et, porta libero. Sed eu."}},
@@ -205,6 +217,93 @@ func TestWrite(t *testing.T) {
 	}
 }
 
+func TestWrite_DecodeRoundTrip(t *testing.T) {
+	roundTrip := func(t *testing.T, d *Decoder) ([]byte, interface{}) {
+		t.Helper()
+		v, err := d.Decode()
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, (&Encoder{}).Write(&buf, v))
+		return buf.Bytes(), v
+	}
+
+	for testCase, tt := range tests {
+		t.Run(testCase, func(t *testing.T) {
+			data, v := roundTrip(t, NewDecoder(tt.encoded))
+			if tt.tokens != nil { // NaN never compares equal
+				again, err := NewDecoder(data).Decode()
+				require.NoError(t, err)
+				assert.Equal(t, v, again)
+			}
+
+			// with the wire form kept, the bytes come back unchanged
+			d := NewDecoder(tt.encoded)
+			d.IntFormat = IntFormatNumber
+			d.OrderedDicts = true
+			data, _ = roundTrip(t, d)
+			assert.Equal(t, tt.encoded, data)
+		})
+	}
+
+	for name, fixture := range specFixtures {
+		fixture := fixture
+		t.Run("spec/"+name, func(t *testing.T) {
+			data, v := roundTrip(t, NewDecoder(fixture(t)))
+			again, err := NewDecoder(data).Decode()
+			require.NoError(t, err)
+			if strings.Contains(name, "NaN") {
+				// NaN never compares equal, so compare the encodings instead
+				var buf bytes.Buffer
+				require.NoError(t, (&Encoder{}).Write(&buf, again))
+				assert.Equal(t, data, buf.Bytes())
+				return
+			}
+			assert.Equal(t, v, again)
+		})
+	}
+
+	t.Run("int_keys", func(t *testing.T) {
+		for _, in := range []interface{}{
+			map[int8]string{1: "a", -2: "b"},
+			map[uint32]int{7: 1, 1 << 20: 2},
+			map[int64]interface{}{1 << 40: map[int]bool{3: true}},
+			map[int]string{5: "x", -300: "y"},
+		} {
+			data, v := roundTrip(t, NewDecoder(encode(t, in)))
+			again, err := NewDecoder(data).Decode()
+			require.NoError(t, err)
+			assert.Equal(t, v, again)
+		}
+
+		// 64-bit keys decode as int64/uint64 and keep their width
+		in := encode(t, map[uint64]string{1 << 40: "a", 2: "b"})
+		data, _ := roundTrip(t, NewDecoder(in))
+		assert.Equal(t, byte(typeUint64), data[1])
+
+		// Narrower keys decode as int under the default IntFormat, which
+		// carries no width, so they come back as SLEB128 keys. IntFormatNumber
+		// keeps the type byte and so every width.
+		for _, in := range []interface{}{
+			Dict{{Key: int8(-2), Value: "a"}, {Key: int8(1), Value: "b"}},
+			Dict{{Key: int16(-300), Value: "a"}, {Key: int16(7), Value: "b"}},
+			Dict{{Key: int32(1 << 20), Value: "a"}, {Key: int32(-1), Value: "b"}},
+			Dict{{Key: uint8(200), Value: "a"}, {Key: uint8(3), Value: "b"}},
+			Dict{{Key: uint16(60000), Value: "a"}, {Key: uint16(1), Value: "b"}},
+			Dict{{Key: uint32(1 << 31), Value: "a"}, {Key: uint32(2), Value: "b"}},
+		} {
+			fixture := encode(t, in)
+			data, _ := roundTrip(t, NewDecoder(fixture))
+			assert.Equal(t, byte(0xBB), data[1], "%T", in.(Dict)[0].Key)
+
+			d := NewDecoder(fixture)
+			d.IntFormat = IntFormatNumber
+			d.OrderedDicts = true
+			data, _ = roundTrip(t, d)
+			assert.Equal(t, fixture, data, "%T", in.(Dict)[0].Key)
+		}
+	})
+}
+
 func TestWriteMagic(t *testing.T) {
 	var buf bytes.Buffer
 	var enc Encoder
@@ -450,6 +549,320 @@ func TestDecoder(t *testing.T) {
 	})
 }
 
+type labelled struct {
+	Label string
+	Value int
+}
+
+// MarshalMuonTo writes the label through the encoder so it takes part in LRU
+// deduplication.
+func (l labelled) MarshalMuonTo(enc *Encoder, w io.Writer) error {
+	return enc.Write(w, []interface{}{l.Label, l.Value})
+}
+
+func TestMarshalerTo_SharesLRU(t *testing.T) {
+	var buf bytes.Buffer
+	enc := Encoder{LRU: true}
+	assert.Nil(t, enc.Write(&buf, []interface{}{"kg", labelled{Label: "kg", Value: 3}}))
+
+	assert.Equal(t, []byte{
+		listStart,
+		tagRefString, 'k', 'g', stringEnd,
+		listStart, stringRef, 0x00, 0xa3, listEnd,
+		listEnd,
+	}, buf.Bytes())
+
+	v, err := NewDecoder(buf.Bytes()).Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"kg", []interface{}{"kg", 3}}, v)
+}
+
+type rawMarshal []byte
+
+func (r rawMarshal) MarshalMuon() ([]byte, error) {
+	return r, nil
+}
+
+type rawStreamMarshal []byte
+
+func (r rawStreamMarshal) MarshalMuon(w io.Writer) error {
+	_, err := w.Write(r)
+	return err
+}
+
+func TestStrictMarshaler(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := Encoder{StrictMarshaler: true}
+		assert.Nil(t, enc.Write(&buf, rawMarshal{listStart, 0xa1, listEnd, tagPadding}))
+		assert.Equal(t, []byte{listStart, 0xa1, listEnd, tagPadding}, buf.Bytes())
+	})
+
+	invalid := map[string][]byte{
+		"empty":          {},
+		"incomplete":     {listStart, 0xa1},
+		"two_values":     {0xa1, 0xa2},
+		"unknown_ref":    {stringRef, 0x00},
+		"unterminated":   {'a', 'b'},
+		"stray_list_end": {listEnd},
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			enc := Encoder{StrictMarshaler: true}
+			assert.Error(t, enc.Write(&bytes.Buffer{}, rawMarshal(data)))
+			assert.Error(t, enc.Write(&bytes.Buffer{}, rawStreamMarshal(data)))
+		})
+	}
+
+	t.Run("not_strict", func(t *testing.T) {
+		var buf bytes.Buffer
+		var enc Encoder
+		assert.Nil(t, enc.Write(&buf, rawMarshal{0xa1, 0xa2}))
+		assert.Equal(t, []byte{0xa1, 0xa2}, buf.Bytes())
+	})
+
+	t.Run("lru_in_sync", func(t *testing.T) {
+		// the marshaler introduces "kg" into the reader's table; the encoder
+		// must account for it so the following reference points at "m".
+		var buf bytes.Buffer
+		enc := Encoder{LRU: true, StrictMarshaler: true}
+		in := []interface{}{"m", rawMarshal{tagRefString, 'k', 'g', stringEnd}, "m"}
+		assert.Nil(t, enc.Write(&buf, in))
+
+		v, err := NewDecoder(buf.Bytes()).Decode()
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{"m", "kg", "m"}, v)
+	})
+}
+
+type money struct {
+	Cents int64
+}
+
+// MarshalMuon has a pointer receiver on purpose: the encoder must find it on
+// fields, elements and map values that are not pointers themselves.
+func (m *money) MarshalMuon() ([]byte, error) {
+	return []byte{0xa7}, nil
+}
+
+type streamMoney struct{}
+
+func (m *streamMoney) MarshalMuon(w io.Writer) error {
+	_, err := w.Write([]byte{0xa8})
+	return err
+}
+
+func TestPointerReceiverMarshaler(t *testing.T) {
+	type wallet struct {
+		Main money
+	}
+	cases := map[string]struct {
+		in       interface{}
+		expected []byte
+	}{
+		"value":          {in: money{}, expected: []byte{0xa7}},
+		"pointer":        {in: &money{}, expected: []byte{0xa7}},
+		"nil_pointer":    {in: (*money)(nil), expected: []byte{nilValue}},
+		"stream":         {in: streamMoney{}, expected: []byte{0xa8}},
+		"struct_field":   {in: wallet{}, expected: []byte{dictStart, 'm', 'a', 'i', 'n', stringEnd, 0xa7, dictEnd}},
+		"struct_pointer": {in: &wallet{}, expected: []byte{dictStart, 'm', 'a', 'i', 'n', stringEnd, 0xa7, dictEnd}},
+		"slice_elem":     {in: []money{{}, {}}, expected: []byte{listStart, 0xa7, 0xa7, listEnd}},
+		"array_elem":     {in: [1]money{}, expected: []byte{listStart, 0xa7, listEnd}},
+		"map_value":      {in: map[string]money{"a": {}}, expected: []byte{dictStart, 'a', stringEnd, 0xa7, dictEnd}},
+		"interface_elem": {in: []interface{}{money{}}, expected: []byte{listStart, 0xa7, listEnd}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var enc Encoder
+			assert.Nil(t, enc.Write(&buf, tc.in))
+			assert.Equal(t, tc.expected, buf.Bytes())
+		})
+	}
+}
+
+type celsius int16
+
+func (c celsius) MarshalMuon() ([]byte, error) {
+	return []byte{0xa0 + byte(c)}, nil
+}
+
+func TestMarshalerElemsNotTypedArray(t *testing.T) {
+	// a numeric slice whose element type has its own encoding must not be
+	// packed as a TypedArray
+	var buf bytes.Buffer
+	var enc Encoder
+	assert.Nil(t, enc.Write(&buf, []celsius{1, 2}))
+	assert.Equal(t, []byte{listStart, 0xa1, 0xa2, listEnd}, buf.Bytes())
+}
+
+type blob struct{ data []byte }
+
+func (b blob) MarshalBinary() ([]byte, error) { return b.data, nil }
+
+func (b *blob) UnmarshalBinary(data []byte) error {
+	b.data = append([]byte(nil), data...)
+	return nil
+}
+
+// textAndBinary implements both; the text form must win.
+type textAndBinary struct{}
+
+func (textAndBinary) MarshalText() ([]byte, error)   { return []byte("t"), nil }
+func (textAndBinary) MarshalBinary() ([]byte, error) { return []byte{1}, nil }
+
+// muonAndText implements both; the muon form must win.
+type muonAndText struct{}
+
+func (muonAndText) MarshalMuon() ([]byte, error) { return []byte{0xa1}, nil }
+func (muonAndText) MarshalText() ([]byte, error) { return []byte("t"), nil }
+
+func TestEncodingMarshalers(t *testing.T) {
+	cases := map[string]struct {
+		in       interface{}
+		expected []byte
+	}{
+		"net_ip":          {in: net.IPv4(10, 0, 0, 1), expected: []byte("10.0.0.1\x00")},
+		"text_key_type":   {in: upperKey{'a', 'b', 'c'}, expected: []byte("ABC\x00")},
+		"big_int":         {in: *big.NewInt(12), expected: []byte{0xBB, 0x0C}}, // math/big takes precedence
+		"binary":          {in: blob{data: []byte{1, 2}}, expected: []byte{typedArray, typeUint8, 0x02, 0x01, 0x02}},
+		"text_over_bin":   {in: textAndBinary{}, expected: []byte{'t', stringEnd}},
+		"muon_over_text":  {in: muonAndText{}, expected: []byte{0xa1}},
+		"text_slice_elem": {in: []net.IP{net.IPv4(1, 2, 3, 4)}, expected: []byte("\x901.2.3.4\x00\x91")},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var enc Encoder
+			assert.Nil(t, enc.Write(&buf, tc.in))
+			assert.Equal(t, tc.expected, buf.Bytes())
+		})
+	}
+}
+
+// upperKey is a non-string map key type with a text form.
+type upperKey [3]byte
+
+func (k upperKey) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(k[:]))), nil
+}
+
+func (k *upperKey) UnmarshalText(text []byte) error {
+	copy(k[:], strings.ToLower(string(text)))
+	return nil
+}
+
+func TestTextMarshalerMapKeys(t *testing.T) {
+	m := map[upperKey]int{{'b', 'b', 'b'}: 2, {'a', 'a', 'a'}: 1}
+	var buf bytes.Buffer
+	enc := Encoder{Deterministic: true}
+	assert.Nil(t, enc.Write(&buf, m))
+	assert.Equal(t, []byte("\x92AAA\x00\xa1BBB\x00\xa2\x93"), buf.Bytes())
+}
+
+func TestOmitEmpty(t *testing.T) {
+	type S struct {
+		B   bool              `muon:"b,omitempty"`
+		I   int               `muon:"i,omitempty"`
+		U   uint8             `muon:"u,omitempty"`
+		F   float64           `muon:"f,omitempty"`
+		S   string            `muon:"s,omitempty"`
+		P   *int              `muon:"p,omitempty"`
+		L   []int             `muon:"l,omitempty"`
+		M   map[string]int    `muon:"m,omitempty"`
+		A   [0]int            `muon:"a,omitempty"`
+		X   interface{}       `muon:"x,omitempty"`
+		St  struct{ V int }   `muon:"st,omitempty"` // structs are never empty
+		Kep string            `muon:"kep"`
+		E   map[string]string `muon:"e,omitempty"`
+	}
+	var buf bytes.Buffer
+	var enc Encoder
+	assert.Nil(t, enc.Write(&buf, S{E: map[string]string{}}))
+	assert.Equal(t, []byte("\x92st\x00\x92v\x00\xa0\x93kep\x00\x00\x93"), buf.Bytes())
+
+	zero := 0
+	buf.Reset()
+	assert.Nil(t, enc.Write(&buf, S{I: 1, P: &zero, L: []int{}, Kep: "k"}))
+	assert.Equal(t, []byte("\x92i\x00\xa1p\x00\xa0st\x00\x92v\x00\xa0\x93kep\x00k\x00\x93"), buf.Bytes())
+}
+
+// evenZero treats every even number as zero.
+type evenZero int
+
+func (z evenZero) IsZero() bool { return z%2 == 0 }
+
+// ptrZero implements IsZero with a pointer receiver.
+type ptrZero struct{ V int }
+
+func (z *ptrZero) IsZero() bool { return z.V < 0 }
+
+func TestOmitZero(t *testing.T) {
+	type S struct {
+		Inner struct{ V int } `muon:"inner,omitzero"`
+		Time  time.Time       `muon:"time,omitzero"`
+		Even  evenZero        `muon:"even,omitzero"`
+		Ptr   ptrZero         `muon:"ptr,omitzero"`
+		Nil   *evenZero       `muon:"nil,omitzero"`
+		Empty []int           `muon:"empty,omitzero"`
+	}
+	var enc Encoder
+	var buf bytes.Buffer
+	assert.Nil(t, enc.Write(&buf, S{Even: 2, Ptr: ptrZero{V: -1}}))
+	// a non-nil empty slice is not the zero value
+	assert.Equal(t, []byte("\x92\x93"), buf.Bytes())
+
+	buf.Reset()
+	assert.Nil(t, enc.Write(&buf, S{Even: 3, Ptr: ptrZero{V: 0}, Empty: []int{}}))
+	assert.Equal(t, []byte("\x92even\x00\xa3ptr\x00\x92v\x00\xa0\x93empty\x00\x90\x91\x93"), buf.Bytes())
+}
+
+type treeNode struct {
+	Value    int         `muon:"v"`
+	Children []*treeNode `muon:"c,omitempty"`
+}
+
+func TestEncoder_RecursiveType(t *testing.T) {
+	in := &treeNode{Value: 1, Children: []*treeNode{{Value: 2}, {Value: 3, Children: []*treeNode{{Value: 4}}}}}
+
+	var out treeNode
+	require.NoError(t, Unmarshal(encode(t, in), &out))
+	assert.Equal(t, *in, out)
+}
+
+func TestEncoder_ConcurrentFirstUse(t *testing.T) {
+	type row struct {
+		A int               `muon:"a"`
+		B map[string][]int8 `muon:"b"`
+		C *row              `muon:"c"`
+	}
+	in := row{A: 1, B: map[string][]int8{"x": {1, 2}}, C: &row{A: 2}}
+	want := encode(t, in)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var buf bytes.Buffer
+			var enc Encoder
+			if err := enc.Write(&buf, in); err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.Equal(want, buf.Bytes()) {
+				errs <- fmt.Errorf("got % X, want % X", buf.Bytes(), want)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
 func BenchmarkWrite(b *testing.B) {
 	for testCase, tt := range tests {
 		var writer DummyWriter
@@ -467,8 +880,179 @@ func BenchmarkWrite(b *testing.B) {
 	}
 }
 
+type benchRecord struct {
+	ID      int64             `muon:"id"`
+	Name    string            `muon:"name"`
+	Score   float64           `muon:"score"`
+	Active  bool              `muon:"active"`
+	Tags    []string          `muon:"tags,omitempty"`
+	Samples []float32         `muon:"samples"`
+	Attrs   map[string]string `muon:"attrs"`
+}
+
+func benchRecords(n int) []benchRecord {
+	out := make([]benchRecord, n)
+	for i := range out {
+		out[i] = benchRecord{
+			ID:      int64(i),
+			Name:    "record",
+			Score:   float64(i) / 3,
+			Active:  i%2 == 0,
+			Tags:    []string{"a", "b"},
+			Samples: []float32{1, 2, 3, 4},
+			Attrs:   map[string]string{"k": "v"},
+		}
+	}
+	return out
+}
+
+func BenchmarkWrite_StructSlice(b *testing.B) {
+	records := benchRecords(10000)
+	var writer DummyWriter
+	var encoder Encoder
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := encoder.Write(&writer, records); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWrite_StructSliceParallel(b *testing.B) {
+	records := benchRecords(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var writer DummyWriter
+		var encoder Encoder
+		for pb.Next() {
+			if err := encoder.Write(&writer, records); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 type DummyWriter struct{}
 
 func (d DummyWriter) Write(_ []byte) (int, error) {
 	return 0, nil
 }
+
+func TestEncoder_IntSlices(t *testing.T) {
+	tests := []struct {
+		name   string
+		format IntSliceFormat
+		in     interface{}
+		want   []byte
+	}{
+		{"list", IntSliceList, []int{1, 300}, []byte{listStart, 0xA1, 0xBB, 0xAC, 0x02, listEnd}},
+		{"int64", IntSlice64, []int{1, -1}, []byte{typedArray, typeInt64, 2, 1, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		{"uint64", IntSlice64, []uint{2}, []byte{typedArray, typeUint64, 1, 2, 0, 0, 0, 0, 0, 0, 0}},
+		{"smallest int8", IntSliceSmallest, []int{-128, 127}, []byte{typedArray, typeInt8, 2, 0x80, 0x7F}},
+		{"smallest int16", IntSliceSmallest, [2]int{-129, 0}, []byte{typedArray, typeInt16, 2, 0x7F, 0xFF, 0, 0}},
+		{"smallest int32", IntSliceSmallest, []int{1 << 20}, []byte{typedArray, typeInt32, 1, 0, 0, 0x10, 0}},
+		{"smallest uint8", IntSliceSmallest, []uint{255}, []byte{typedArray, typeUint8, 1, 0xFF}},
+		{"smallest uint16", IntSliceSmallest, []uint{256}, []byte{typedArray, typeUint16, 1, 0, 1}},
+		{"smallest empty", IntSliceSmallest, []int{}, []byte{typedArray, typeInt8, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, encodeWith(t, &Encoder{IntSlices: tt.format}, tt.in))
+		})
+	}
+
+	// fields and nested slices follow the option too
+	type S struct {
+		IDs []int `muon:"ids"`
+	}
+	data := encodeWith(t, &Encoder{IntSlices: IntSliceSmallest}, map[string]interface{}{"s": S{IDs: []int{1, 2}}})
+	var out map[string]S
+	require.NoError(t, Unmarshal(data, &out))
+	assert.Equal(t, []int{1, 2}, out["s"].IDs)
+	assert.Contains(t, string(data), string([]byte{typedArray, typeInt8, 2, 1, 2}))
+}
+
+func TestEncoder_DetectTypedArrays(t *testing.T) {
+	type Level int16 // named types count by their kind
+	tests := []struct {
+		name string
+		in   interface{}
+		want interface{} // decoded TypedArray, or nil for a list
+	}{
+		{"float32 exact", []interface{}{1.5, -0.25, math.Inf(1)}, []float32{1.5, -0.25, float32(math.Inf(1))}},
+		{"float64", []interface{}{0.1, 2.0}, []float64{0.1, 2}},
+		{"mixed float kinds", []interface{}{float32(0.5), 0.1}, []float64{0.5, 0.1}},
+		{"int8", []interface{}{1, -128, int64(127)}, []int8{1, -128, 127}},
+		{"uint8", []interface{}{0, 255}, []uint8{0, 255}},
+		{"int16", []interface{}{-1, Level(300)}, []int16{-1, 300}},
+		{"uint32", []interface{}{uint(1 << 31)}, []uint32{1 << 31}},
+		{"int64", []interface{}{-1, 1 << 40}, []int64{-1, 1 << 40}},
+		{"uint64", []interface{}{uint64(math.MaxUint64)}, []uint64{math.MaxUint64}},
+		{"array", [2]interface{}{1, 2}, []int8{1, 2}},
+		{"ints and floats", []interface{}{1, 1.5}, nil},
+		{"strings", []interface{}{"a"}, nil},
+		{"nil element", []interface{}{1, nil}, nil},
+		{"duration", []interface{}{time.Second}, nil},
+		{"too wide", []interface{}{-1, uint64(math.MaxUint64)}, nil},
+		{"empty", []interface{}{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeWith(t, &Encoder{DetectTypedArrays: true}, tt.in)
+			toks := tokens(t, data)
+			if tt.want == nil {
+				assert.Equal(t, TokenListStart, toks[0].A)
+				return
+			}
+			assert.Equal(t, []Token{{A: TokenTypedArray, Data: tt.want}}, toks)
+		})
+	}
+
+	// off by default
+	assert.Equal(t, byte(listStart), encode(t, []interface{}{1.5})[0])
+}
+
+func TestEncoder_DisableTypedArrays(t *testing.T) {
+	enc := &Encoder{DisableTypedArrays: true, DetectTypedArrays: true, IntSlices: IntSlice64, Columnar: true}
+	tests := []struct {
+		name string
+		in   interface{}
+		want []byte
+	}{
+		{"bytes", []byte{1, 2}, []byte{listStart, 0xA1, 0xA2, listEnd}},
+		{"int32", [1]int32{-1}, []byte{listStart, 0xBB, 0x7F, listEnd}},
+		{"float32", []float32{1.5}, append(append([]byte{listStart}, encode(t, 1.5)...), listEnd)},
+		{"int", []int{3}, []byte{listStart, 0xA3, listEnd}},
+		{"interface", []interface{}{3}, []byte{listStart, 0xA3, listEnd}},
+		{"binary marshaler", blob{data: []byte{4}}, []byte{listStart, 0xA4, listEnd}},
+		{"columnar", []sample{{T: 5}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeWith(t, enc, tt.in)
+			if tt.want != nil {
+				assert.Equal(t, tt.want, data)
+			}
+			for _, tok := range tokens(t, data) {
+				assert.NotEqual(t, TokenTypedArray, tok.A)
+			}
+		})
+	}
+
+	// lists still decode into typed slices
+	var out []float32
+	require.NoError(t, Unmarshal(encodeWith(t, enc, []float32{1.5, 2}), &out))
+	assert.Equal(t, []float32{1.5, 2}, out)
+}
+
+func TestEncoder_RejectInvalidUTF8(t *testing.T) {
+	enc := &Encoder{RejectInvalidUTF8: true, LRU: true}
+	var buf bytes.Buffer
+	assert.Error(t, enc.Write(&buf, "\xFFbad"))
+	assert.Error(t, enc.Write(&buf, map[string]int{"a\x90": 1}))
+	assert.Empty(t, enc.lru, "rejected strings must not enter the LRU")
+
+	assert.Equal(t, []byte{tagRefString, 'o', 'k', stringEnd}, encodeWith(t, enc, "ok"))
+}